@@ -0,0 +1,419 @@
+package goexpose
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+apikey authorizer
+
+apikey authorizer verifies key from request header or query param against key
+file. Key file does not contain keys, only their salted hashes, so every key can
+be revoked by removing its line. Every line of key file has form:
+
+	<name> <hash> [expires=<date>] [endpoints=<pattern>,...]
+
+Lines starting with "#" are comments. Key file is checked for changes at most
+once per reload interval.
+*/
+
+const (
+	// algorithm prefix of key hash
+	APIKEY_HASH_SHA256 = "sha256"
+
+	// size of generated key and salt in bytes
+	apiKeySize  = 32
+	apiSaltSize = 16
+)
+
+var (
+	ErrAPIKeyMissing     = errors.New("apikey: key missing")
+	ErrAPIKeyInvalid     = errors.New("apikey: invalid key")
+	ErrAPIKeyExpired     = errors.New("apikey: key expired")
+	ErrAPIKeyNotAllowed  = errors.New("apikey: key is not allowed for endpoint")
+	ErrAPIKeyInvalidHash = errors.New("apikey: invalid hash")
+)
+
+/*
+APIKeyAuthorizerConfig is configuration of apikey authorizer
+*/
+type APIKeyAuthorizerConfig struct {
+	// file with names and hashes of keys
+	KeysFile string `json:"keys_file"`
+
+	// how often keys file is checked for changes (default 10s)
+	ReloadInterval Duration `json:"reload_interval"`
+
+	// header with key (default "X-API-Key"), empty header disables it
+	Header string `json:"header"`
+
+	// query param with key, used when header is not present
+	QueryParam string `json:"query_param"`
+}
+
+/*
+Validate validates apikey authorizer config
+*/
+func (a *APIKeyAuthorizerConfig) Validate() (err error) {
+	a.KeysFile = strings.TrimSpace(a.KeysFile)
+	a.Header = strings.TrimSpace(a.Header)
+	a.QueryParam = strings.TrimSpace(a.QueryParam)
+
+	if a.KeysFile == "" {
+		return errors.New("apikey: please provide keys_file")
+	}
+	if a.Header == "" && a.QueryParam == "" {
+		return errors.New("apikey: please provide header or query_param")
+	}
+
+	if a.ReloadInterval < 0 {
+		return errors.New("apikey: reload_interval must not be negative")
+	}
+	if a.ReloadInterval == 0 {
+		a.ReloadInterval = Duration(DEFAULT_APIKEY_RELOAD_INTERVAL)
+	}
+	return
+}
+
+/*
+newAPIKeyAuthorizerConfig returns validated config of authorizer config
+*/
+func newAPIKeyAuthorizerConfig(ac *AuthorizerConfig) (config *APIKeyAuthorizerConfig, err error) {
+	config = &APIKeyAuthorizerConfig{
+		Header: DEFAULT_APIKEY_HEADER,
+	}
+	if err = decodeConfig(ac.Config, config); err != nil {
+		return
+	}
+	err = config.Validate()
+	return
+}
+
+func APIKeyAuthorizerFactory(ac *AuthorizerConfig) (result Authorizer, err error) {
+	var config *APIKeyAuthorizerConfig
+	if config, err = newAPIKeyAuthorizerConfig(ac); err != nil {
+		return
+	}
+
+	authorizer := &APIKeyAuthorizer{
+		config: config,
+	}
+
+	if authorizer.keys, err = readAPIKeysFile(config.KeysFile); err != nil {
+		return nil, fmt.Errorf("apikey: keys file %s: %v", config.KeysFile, err)
+	}
+	authorizer.watcher = newFileWatcher(time.Duration(config.ReloadInterval), nil, config.KeysFile)
+	authorizer.checked = time.Now()
+
+	result = authorizer
+	return
+}
+
+/*
+APIKeySecurityScheme describes apikey authorizer, header is preferred when both
+header and query param are configured.
+*/
+func APIKeySecurityScheme(ac *AuthorizerConfig) Schema {
+	config, err := newAPIKeyAuthorizerConfig(ac)
+	if err != nil {
+		return nil
+	}
+	if config.Header != "" {
+		return Schema{"type": "apiKey", "in": "header", "name": config.Header}
+	}
+	return Schema{"type": "apiKey", "in": "query", "name": config.QueryParam}
+}
+
+/*
+APIKey is single entry of keys file
+*/
+type APIKey struct {
+	// name of key, it's logged as user
+	Name string
+
+	// salt and sha256 of salt and key
+	Salt []byte
+	Hash []byte
+
+	// zero time means key does not expire
+	Expires time.Time
+
+	// patterns of endpoint paths, empty means all endpoints
+	Endpoints []string
+}
+
+/*
+Matches returns whether key matches hash of entry
+*/
+func (a *APIKey) Matches(key string) bool {
+	return subtle.ConstantTimeCompare(hashAPIKey(a.Salt, key), a.Hash) == 1
+}
+
+/*
+Allowed returns whether key can be used for given route or request path
+*/
+func (a *APIKey) Allowed(paths ...string) bool {
+	return len(a.Endpoints) == 0 || matchAnyPattern(a.Endpoints, paths...)
+}
+
+/*
+String returns line of keys file
+*/
+func (a *APIKey) String() string {
+	parts := []string{
+		a.Name,
+		fmt.Sprintf("%s:%s:%s", APIKEY_HASH_SHA256, base64.RawURLEncoding.EncodeToString(a.Salt), base64.RawURLEncoding.EncodeToString(a.Hash)),
+	}
+	if !a.Expires.IsZero() {
+		parts = append(parts, "expires="+a.Expires.Format(time.RFC3339))
+	}
+	if len(a.Endpoints) > 0 {
+		parts = append(parts, "endpoints="+strings.Join(a.Endpoints, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+/*
+APIKeyAuthorizer verifies keys against keys file
+*/
+type APIKeyAuthorizer struct {
+	config *APIKeyAuthorizerConfig
+
+	// keys file is checked for changes at most once per reload interval
+	lock    sync.RWMutex
+	keys    []*APIKey
+	watcher *fileWatcher
+	checked time.Time
+}
+
+/*
+Authorize verifies key from header or query param
+*/
+func (a *APIKeyAuthorizer) Authorize(r *http.Request) (err error) {
+	key := ""
+	if a.config.Header != "" {
+		key = strings.TrimSpace(r.Header.Get(a.config.Header))
+	}
+	if key == "" && a.config.QueryParam != "" {
+		key = strings.TrimSpace(r.URL.Query().Get(a.config.QueryParam))
+	}
+	if key == "" {
+		return ErrAPIKeyMissing
+	}
+
+	// reload is logged with request id
+	a.check(requestLogger(r.Context()))
+
+	var entry *APIKey
+	if entry, err = a.Verify(key, time.Now()); err != nil {
+		return
+	}
+
+	if !entry.Allowed(getRequestState(r.Context()).Route, r.URL.Path) {
+		return ErrAPIKeyNotAllowed
+	}
+
+	SetRequestUser(r, entry.Name)
+	return
+}
+
+/*
+Verify returns entry of key that is valid at given time
+*/
+func (a *APIKeyAuthorizer) Verify(key string, now time.Time) (entry *APIKey, err error) {
+	a.check(logger())
+
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	for _, item := range a.keys {
+		if item.Matches(key) {
+			entry = item
+			break
+		}
+	}
+	if entry == nil {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	if !entry.Expires.IsZero() && !now.Before(entry.Expires) {
+		return nil, ErrAPIKeyExpired
+	}
+	return
+}
+
+/*
+check checks keys file for changes when reload interval passed, reload is logged to given logger
+*/
+func (a *APIKeyAuthorizer) check(log Logger) {
+	a.lock.Lock()
+	if time.Since(a.checked) < time.Duration(a.config.ReloadInterval) {
+		a.lock.Unlock()
+		return
+	}
+	a.checked = time.Now()
+	a.lock.Unlock()
+
+	if len(a.watcher.Changed()) > 0 {
+		a.reloadKeys(log)
+	}
+}
+
+/*
+reloadKeys reloads keys file, on error old keys are kept
+*/
+func (a *APIKeyAuthorizer) reloadKeys(log Logger) {
+	keys, err := readAPIKeysFile(a.config.KeysFile)
+	if err != nil {
+		log.Errorf("Reload of keys file %s failed, keeping old keys: %v", a.config.KeysFile, err)
+		return
+	}
+
+	a.lock.Lock()
+	a.keys = keys
+	a.lock.Unlock()
+
+	log.Infof("Loaded %d api keys from %s", len(keys), a.config.KeysFile)
+}
+
+/*
+GenerateAPIKey returns new random key and its entry for keys file
+*/
+func GenerateAPIKey(name string, expires time.Time, endpoints []string) (key string, entry *APIKey, err error) {
+	if name = strings.TrimSpace(name); name == "" || strings.ContainsAny(name, " \t") {
+		return "", nil, errors.New("apikey: name must not be empty or contain whitespace")
+	}
+	for _, endpoint := range endpoints {
+		if _, err = path.Match(endpoint, ""); err != nil || strings.ContainsAny(endpoint, ", \t") {
+			return "", nil, fmt.Errorf("apikey: invalid endpoint pattern %s", endpoint)
+		}
+	}
+
+	random := make([]byte, apiKeySize+apiSaltSize)
+	if _, err = rand.Read(random); err != nil {
+		return
+	}
+
+	key = base64.RawURLEncoding.EncodeToString(random[:apiKeySize])
+	entry = &APIKey{
+		Name:      name,
+		Salt:      random[apiKeySize:],
+		Expires:   expires,
+		Endpoints: endpoints,
+	}
+	entry.Hash = hashAPIKey(entry.Salt, key)
+	return
+}
+
+/*
+hashAPIKey returns sha256 of salt and key
+*/
+func hashAPIKey(salt []byte, key string) []byte {
+	hash := sha256.New()
+	hash.Write(salt)
+	hash.Write([]byte(key))
+	return hash.Sum(nil)
+}
+
+/*
+ParseAPIKey parses line of keys file
+*/
+func ParseAPIKey(line string) (result *APIKey, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, errors.New("name and hash expected")
+	}
+
+	result = &APIKey{
+		Name: fields[0],
+	}
+
+	hash := strings.Split(fields[1], ":")
+	if len(hash) != 3 || hash[0] != APIKEY_HASH_SHA256 {
+		return nil, ErrAPIKeyInvalidHash
+	}
+	if result.Salt, err = base64.RawURLEncoding.DecodeString(hash[1]); err != nil || len(result.Salt) == 0 {
+		return nil, ErrAPIKeyInvalidHash
+	}
+	if result.Hash, err = base64.RawURLEncoding.DecodeString(hash[2]); err != nil || len(result.Hash) != sha256.Size {
+		return nil, ErrAPIKeyInvalidHash
+	}
+
+	for _, option := range fields[2:] {
+		splitted := strings.SplitN(option, "=", 2)
+		if len(splitted) != 2 {
+			return nil, fmt.Errorf("invalid option %s", option)
+		}
+
+		switch splitted[0] {
+		case "expires":
+			if result.Expires, err = parseAPIKeyExpires(splitted[1]); err != nil {
+				return nil, fmt.Errorf("invalid expires %s", splitted[1])
+			}
+		case "endpoints":
+			for _, endpoint := range strings.Split(splitted[1], ",") {
+				if _, err = path.Match(endpoint, ""); err != nil || endpoint == "" {
+					return nil, fmt.Errorf("invalid endpoint pattern %s", endpoint)
+				}
+				result.Endpoints = append(result.Endpoints, endpoint)
+			}
+		default:
+			return nil, fmt.Errorf("unknown option %s", splitted[0])
+		}
+	}
+	return
+}
+
+/*
+parseAPIKeyExpires parses expiration as RFC3339 time or date (key expires at start of day in UTC)
+*/
+func parseAPIKeyExpires(value string) (result time.Time, err error) {
+	if result, err = time.Parse(time.RFC3339, value); err == nil {
+		return
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+/*
+readAPIKeysFile reads entries from keys file, names of keys must be unique
+*/
+func readAPIKeysFile(filename string) (result []*APIKey, err error) {
+	var body []byte
+	if body, err = ioutil.ReadFile(filename); err != nil {
+		return
+	}
+
+	names := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var entry *APIKey
+		if entry, err = ParseAPIKey(line); err != nil {
+			return nil, fmt.Errorf("line %d: %v", number, err)
+		}
+		if names[entry.Name] {
+			return nil, fmt.Errorf("line %d: duplicate key name %s", number, entry.Name)
+		}
+		names[entry.Name] = true
+		result = append(result, entry)
+	}
+	err = scanner.Err()
+	return
+}
@@ -0,0 +1,94 @@
+package goexpose
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAPIKeyAuthorizer(t *testing.T) {
+
+	Convey("Test generate and parse key", t, func() {
+		expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		key, entry, err := GenerateAPIKey("deploy", expires, []string{"/deploy/*", "/info"})
+		So(err, ShouldBeNil)
+		So(entry.String(), ShouldNotContainSubstring, key)
+
+		parsed, err := ParseAPIKey(entry.String())
+		So(err, ShouldBeNil)
+		So(parsed.Name, ShouldEqual, "deploy")
+		So(parsed.Expires.Equal(expires), ShouldBeTrue)
+		So(parsed.Endpoints, ShouldResemble, []string{"/deploy/*", "/info"})
+		So(parsed.Matches(key), ShouldBeTrue)
+		So(parsed.Matches(key+"x"), ShouldBeFalse)
+
+		_, _, err = GenerateAPIKey("with space", time.Time{}, nil)
+		So(err, ShouldNotBeNil)
+
+		for _, line := range []string{"deploy", "deploy md5:a:b", "deploy " + strings.SplitN(entry.String(), " ", 3)[1] + " unknown=1"} {
+			_, err = ParseAPIKey(line)
+			So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("Test authorize and reload", t, func() {
+		directory, err := ioutil.TempDir("", "goexpose")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(directory)
+
+		deployKey, deploy, _ := GenerateAPIKey("deploy", time.Time{}, []string{"/deploy/*"})
+		oldKey, old, _ := GenerateAPIKey("old", time.Now().Add(-time.Hour), nil)
+
+		filename := filepath.Join(directory, "keys")
+		body := "# keys\n" + deploy.String() + "\n" + old.String() + "\n"
+		So(ioutil.WriteFile(filename, []byte(body), 0600), ShouldBeNil)
+
+		result, err := APIKeyAuthorizerFactory(&AuthorizerConfig{Type: "apikey", Config: json.RawMessage(`{"keys_file": "` + filename + `", "query_param": "key", "reload_interval": 1}`)})
+		So(err, ShouldBeNil)
+		authorizer := result.(*APIKeyAuthorizer)
+
+		authorize := func(url string, header string) error {
+			request, _ := http.NewRequest("GET", url, nil)
+			if header != "" {
+				request.Header.Set(DEFAULT_APIKEY_HEADER, header)
+			}
+			request = request.WithContext(withRequestState(request.Context(), &requestState{}))
+			return authorizer.Authorize(request)
+		}
+
+		So(authorize("/deploy/app", deployKey), ShouldBeNil)
+		So(authorize("/deploy/app?key="+deployKey, ""), ShouldBeNil)
+		So(authorize("/deploy/app", ""), ShouldEqual, ErrAPIKeyMissing)
+		So(authorize("/deploy/app", "invalid"), ShouldEqual, ErrAPIKeyInvalid)
+		So(authorize("/info", deployKey), ShouldEqual, ErrAPIKeyNotAllowed)
+		So(authorize("/info", oldKey), ShouldEqual, ErrAPIKeyExpired)
+
+		// revoke deploy key
+		So(ioutil.WriteFile(filename, []byte(old.String()+"\n"), 0600), ShouldBeNil)
+		authorizer.checked = time.Time{}
+		So(authorize("/deploy/app", deployKey), ShouldEqual, ErrAPIKeyInvalid)
+
+		// invalid file keeps old keys
+		So(ioutil.WriteFile(filename, []byte("invalid\n"), 0600), ShouldBeNil)
+		authorizer.checked = time.Time{}
+		So(authorize("/info", oldKey), ShouldEqual, ErrAPIKeyExpired)
+	})
+
+	Convey("Test invalid config", t, func() {
+		_, err := APIKeyAuthorizerFactory(&AuthorizerConfig{Type: "apikey", Config: json.RawMessage(`{}`)})
+		So(err, ShouldNotBeNil)
+
+		_, err = APIKeyAuthorizerFactory(&AuthorizerConfig{Type: "apikey", Config: json.RawMessage(`{"keys_file": "/nonexistent"}`)})
+		So(err, ShouldNotBeNil)
+
+		_, err = APIKeyAuthorizerFactory(&AuthorizerConfig{Type: "apikey", Config: json.RawMessage(`{"keys_file": "keys", "header": ""}`)})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -1,17 +1,21 @@
 package goexpose
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 
-	"encoding/json"
-
 	"net/url"
 
+	"crypto/x509"
+	"path"
+
 	"github.com/nmcclain/ldap"
 )
 
@@ -35,6 +39,26 @@ func init() {
 	RegisterAuthorizer("basic", BasicAuthorizerFactory)
 	RegisterAuthorizer("ldap", LDAPAuthorizerFactory)
 	RegisterAuthorizer("http", HttpAuthorizerFactory)
+	RegisterAuthorizer("mtls", MTLSAuthorizerFactory)
+	RegisterAuthorizer("jwt", JWTAuthorizerFactory)
+	RegisterAuthorizer("apikey", APIKeyAuthorizerFactory)
+	RegisterAuthorizer("hmac", HMACAuthorizerFactory)
+
+	RegisterAuthorizerSchema("basic", BasicAuthorizerConfig{})
+	RegisterAuthorizerSchema("ldap", LDAPAuthorizerConfig{})
+	RegisterAuthorizerSchema("http", HttpAuthorizerConfig{})
+	RegisterAuthorizerSchema("mtls", MTLSAuthorizerConfig{})
+	RegisterAuthorizerSchema("jwt", JWTAuthorizerConfig{})
+	RegisterAuthorizerSchema("apikey", APIKeyAuthorizerConfig{})
+	RegisterAuthorizerSchema("hmac", HMACAuthorizerConfig{})
+
+	RegisterSecurityScheme("basic", StaticSecurityScheme(Schema{"type": "http", "scheme": "basic"}))
+	RegisterSecurityScheme("ldap", StaticSecurityScheme(Schema{"type": "http", "scheme": "basic"}))
+	RegisterSecurityScheme("http", StaticSecurityScheme(Schema{"type": "http", "scheme": "basic"}))
+	RegisterSecurityScheme("mtls", StaticSecurityScheme(Schema{"type": "mutualTLS"}))
+	RegisterSecurityScheme("jwt", StaticSecurityScheme(Schema{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}))
+	RegisterSecurityScheme("apikey", APIKeySecurityScheme)
+	RegisterSecurityScheme("hmac", HMACSecurityScheme)
 }
 
 /*
@@ -78,41 +102,80 @@ func GetAuthorizers(config *Config) (result Authorizers, err error) {
 	authorizerslock.RLock()
 	defer authorizerslock.RUnlock()
 
-	var authorizer Authorizer
+	var errs ConfigErrors
+
+	names := make([]string, 0, len(config.Authorizers))
+	for an := range config.Authorizers {
+		names = append(names, an)
+	}
+	sort.Strings(names)
+
+	for _, an := range names {
+		ac := config.Authorizers[an]
+		source := config.authorizerSource(an)
+		if ac == nil {
+			errs.Add(source.Wrap(errors.New("authorizer config missing"), ""))
+			continue
+		}
 
-	for an, ac := range config.Authorizers {
 		// validate authorizer config
 		if err = ac.Validate(); err != nil {
-			return
+			errs.Add(source.Wrap(err, "/type"))
+			continue
 		}
 
-		var (
-			factory AuthorizerFactory
-			ok      bool
-		)
-		if factory, ok = authorizers[ac.Type]; !ok {
-			err = fmt.Errorf("authorizer %s does not exist", ac.Type)
-			return
-		}
+		factory := authorizers[ac.Type]
 
+		var authorizer Authorizer
 		if authorizer, err = factory(ac); err != nil {
-			return
+			errs.Add(source.Wrap(err, "/config"))
+			continue
 		}
 		result[an] = authorizer
 	}
 
-	// check task authorizers
+	// check endpoint and task authorizers
 	for i, ec := range config.Endpoints {
-		for _, tc := range ec.Methods {
-			for _, a := range tc.Authorizers {
-				if _, ok := result[a]; !ok {
-					err = fmt.Errorf("task %d, invalid authorizer `%s`.", i, a)
-					return
+		source := config.endpointSource(i)
+		for j, a := range ec.Authorizers {
+			if _, ok := config.Authorizers[a]; !ok {
+				errs.Add(source.WrapEndpoint(fmt.Errorf("invalid authorizer `%s`", a), fmt.Sprintf("/authorizers/%d", j), ec.Path, ""))
+			}
+		}
+		for method, tc := range ec.Methods {
+			for j, a := range tc.Authorizers {
+				if _, ok := config.Authorizers[a]; !ok {
+					pointer := fmt.Sprintf("/methods/%s/authorizers/%d", escapePointer(method), j)
+					errs.Add(source.WrapEndpoint(fmt.Errorf("invalid authorizer `%s`", a), pointer, ec.Path, method))
 				}
 			}
 		}
 	}
 
+	// check authorizers of built-in endpoints
+	if config.Metrics != nil {
+		for j, a := range config.Metrics.Authorizers {
+			if _, ok := config.Authorizers[a]; !ok {
+				errs.Add(config.settingSource("metrics").Wrap(fmt.Errorf("invalid authorizer `%s`", a), fmt.Sprintf("/authorizers/%d", j)))
+			}
+		}
+	}
+	if config.Health != nil {
+		for j, a := range config.Health.Authorizers {
+			if _, ok := config.Authorizers[a]; !ok {
+				errs.Add(config.settingSource("health").Wrap(fmt.Errorf("invalid authorizer `%s`", a), fmt.Sprintf("/authorizers/%d", j)))
+			}
+		}
+	}
+	if config.OpenAPI != nil {
+		for j, a := range config.OpenAPI.Authorizers {
+			if _, ok := config.Authorizers[a]; !ok {
+				errs.Add(config.settingSource("openapi").Wrap(fmt.Errorf("invalid authorizer `%s`", a), fmt.Sprintf("/authorizers/%d", j)))
+			}
+		}
+	}
+
+	err = errs.Err()
 	return
 }
 
@@ -125,6 +188,11 @@ type Authorizers map[string]Authorizer
 Try all authorizers, first that will fail with error, that error will be returned
 */
 func (a Authorizers) Authorize(r *http.Request, config *EndpointConfig) (err error) {
+	// offline request with skipped authorizers
+	if authorizersSkipped(r.Context()) {
+		return
+	}
+
 	check := []string{}
 	for _, an := range config.Authorizers {
 		check = append(check, an)
@@ -136,6 +204,8 @@ func (a Authorizers) Authorize(r *http.Request, config *EndpointConfig) (err err
 	for _, an := range check {
 		authorizer := a[an]
 		if err = authorizer.Authorize(r); err != nil {
+			metrics.authorizationFailures.Inc(an)
+			requestLogger(r.Context()).Debugf("Authorizer %s rejected request: %v", an, err)
 			return
 		}
 	}
@@ -167,7 +237,7 @@ type BasicAuthorizerConfig struct {
 
 func BasicAuthorizerFactory(ac *AuthorizerConfig) (result Authorizer, err error) {
 	config := &BasicAuthorizerConfig{}
-	if err = json.Unmarshal(ac.Config, config); err != nil {
+	if err = decodeConfig(ac.Config, config); err != nil {
 		return
 	}
 
@@ -228,6 +298,7 @@ func (b *BasicAuthorizer) Authorize(r *http.Request) (err error) {
 		return ErrUnauthorized
 	}
 
+	SetRequestUser(r, username)
 	return
 }
 
@@ -280,7 +351,7 @@ func LDAPAuthorizerFactory(ac *AuthorizerConfig) (result Authorizer, err error)
 		Port:    LDAP_DEFAULT_PORT,
 		Network: LDAP_DEFAULT_NETWORK,
 	}
-	if err = json.Unmarshal(ac.Config, config); err != nil {
+	if err = decodeConfig(ac.Config, config); err != nil {
 		return
 	}
 
@@ -305,6 +376,24 @@ type LDAPAuthorizer struct {
 	basic *BasicAuthorizer
 }
 
+/*
+Probes returns check of ldap server
+*/
+func (l *LDAPAuthorizer) Probes() []Probe {
+	address := fmt.Sprintf("%s:%d", l.config.Host, l.config.Port)
+	return []Probe{{
+		Kind: "ldap",
+		Name: address,
+		Check: func(ctx context.Context) (err error) {
+			var conn net.Conn
+			if conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", address); err != nil {
+				return
+			}
+			return conn.Close()
+		},
+	}}
+}
+
 func (l *LDAPAuthorizer) Authorize(r *http.Request) (err error) {
 	var (
 		username string
@@ -358,6 +447,8 @@ func (l *LDAPAuthorizer) Authorize(r *http.Request) (err error) {
 		return err
 	}
 
+	SetRequestUser(r, username)
+
 	return
 }
 
@@ -440,7 +531,7 @@ func NewHttpAuthorizerConfig(ac *AuthorizerConfig) (hac *HttpAuthorizerConfig, e
 		Data:   "",
 	}
 
-	if err = json.Unmarshal(ac.Config, hac); err != nil {
+	if err = decodeConfig(ac.Config, hac); err != nil {
 		return
 	}
 
@@ -485,3 +576,152 @@ func (h *HttpAuthorizerConfig) RenderData(data map[string]interface{}) (result s
 func (h *HttpAuthorizerConfig) RenderMethod(data map[string]interface{}) (result string, err error) {
 	return Interpolate(h.Method, data)
 }
+
+/*
+mtls authorizer
+
+mtls authorizer checks verified client certificate against allow rules.
+Client certificates must be verified by listener (ssl client_ca and client_auth).
+*/
+
+var (
+	ErrClientCertificateMissing    = errors.New("verified client certificate missing")
+	ErrClientCertificateNotAllowed = errors.New("client certificate is not allowed")
+)
+
+func MTLSAuthorizerFactory(ac *AuthorizerConfig) (result Authorizer, err error) {
+	config := &MTLSAuthorizerConfig{}
+	if err = decodeConfig(ac.Config, config); err != nil {
+		return
+	}
+
+	if err = config.Validate(); err != nil {
+		return
+	}
+
+	result = &MTLSAuthorizer{
+		config: config,
+	}
+	return
+}
+
+/*
+MTLSAuthorizerConfig is list of allow rules, certificate must match at least
+one rule. If no rules are given, every verified certificate is allowed.
+*/
+type MTLSAuthorizerConfig struct {
+	Allow []*MTLSAuthorizerRule `json:"allow"`
+}
+
+/*
+MTLSAuthorizerRule matches certificate when all given fields match. Values can
+contain shell patterns (e.g. "*.example.com").
+*/
+type MTLSAuthorizerRule struct {
+	// subject common name
+	CN []string `json:"cn"`
+
+	// subject organizational unit
+	OU []string `json:"ou"`
+
+	// subject alternative names (dns, email, uri, ip)
+	SAN []string `json:"san"`
+}
+
+/*
+Validate validates patterns in rules. Rule without any field would match every
+certificate, so it's an error.
+*/
+func (m *MTLSAuthorizerConfig) Validate() (err error) {
+	for i, rule := range m.Allow {
+		if rule == nil {
+			return fmt.Errorf("mtls: allow rule %d is empty", i)
+		}
+		if len(rule.CN) == 0 && len(rule.OU) == 0 && len(rule.SAN) == 0 {
+			return fmt.Errorf("mtls: allow rule %d has no cn, ou or san", i)
+		}
+		for _, values := range [][]string{rule.CN, rule.OU, rule.SAN} {
+			for _, value := range values {
+				if _, err = path.Match(value, ""); err != nil {
+					return fmt.Errorf("mtls: invalid pattern %s", value)
+				}
+			}
+		}
+	}
+	return
+}
+
+/*
+Matches returns whether certificate matches rule
+*/
+func (m *MTLSAuthorizerRule) Matches(cert *x509.Certificate) bool {
+	if len(m.CN) > 0 && !matchAnyPattern(m.CN, cert.Subject.CommonName) {
+		return false
+	}
+
+	if len(m.OU) > 0 && !matchAnyPattern(m.OU, cert.Subject.OrganizationalUnit...) {
+		return false
+	}
+
+	if len(m.SAN) > 0 {
+		sans := append([]string{}, cert.DNSNames...)
+		sans = append(sans, cert.EmailAddresses...)
+		for _, uri := range cert.URIs {
+			sans = append(sans, uri.String())
+		}
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		if !matchAnyPattern(m.SAN, sans...) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+MTLSAuthorizer implementation
+*/
+type MTLSAuthorizer struct {
+	config *MTLSAuthorizerConfig
+}
+
+/*
+Authorize checks verified peer certificate
+*/
+func (m *MTLSAuthorizer) Authorize(r *http.Request) (err error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ErrClientCertificateMissing
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+
+	if len(m.config.Allow) == 0 {
+		SetRequestUser(r, cert.Subject.CommonName)
+		return
+	}
+
+	for _, rule := range m.config.Allow {
+		if rule.Matches(cert) {
+			SetRequestUser(r, cert.Subject.CommonName)
+			return
+		}
+	}
+
+	return ErrClientCertificateNotAllowed
+}
+
+/*
+matchAnyPattern returns whether any of values matches any of patterns
+*/
+func matchAnyPattern(patterns []string, values ...string) bool {
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if ok, _ := path.Match(pattern, value); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
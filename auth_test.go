@@ -0,0 +1,74 @@
+package goexpose
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMTLSAuthorizer(t *testing.T) {
+
+	newAuthorizer := func(config string) (Authorizer, error) {
+		return MTLSAuthorizerFactory(&AuthorizerConfig{Type: "mtls", Config: json.RawMessage(config)})
+	}
+
+	newRequest := func(cert *x509.Certificate) *http.Request {
+		request, _ := http.NewRequest("GET", "/", nil)
+		if cert != nil {
+			request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		return request
+	}
+
+	client := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "client.example.com", OrganizationalUnit: []string{"ops"}},
+		DNSNames:       []string{"client.internal"},
+		EmailAddresses: []string{"ops@example.com"},
+	}
+
+	Convey("Test rule matching", t, func() {
+		So((&MTLSAuthorizerRule{CN: []string{"*.example.com"}}).Matches(client), ShouldBeTrue)
+		So((&MTLSAuthorizerRule{CN: []string{"other"}}).Matches(client), ShouldBeFalse)
+		So((&MTLSAuthorizerRule{OU: []string{"dev", "ops"}}).Matches(client), ShouldBeTrue)
+		So((&MTLSAuthorizerRule{SAN: []string{"ops@example.com"}}).Matches(client), ShouldBeTrue)
+		So((&MTLSAuthorizerRule{SAN: []string{"*.external"}}).Matches(client), ShouldBeFalse)
+
+		// all fields of rule must match
+		So((&MTLSAuthorizerRule{CN: []string{"*.example.com"}, OU: []string{"dev"}}).Matches(client), ShouldBeFalse)
+	})
+
+	Convey("Test authorize", t, func() {
+		authorizer, err := newAuthorizer(`{"allow": [{"cn": ["other"]}, {"ou": ["ops"], "san": ["client.internal"]}]}`)
+		So(err, ShouldBeNil)
+		So(authorizer.Authorize(newRequest(client)), ShouldBeNil)
+		So(authorizer.Authorize(newRequest(nil)), ShouldEqual, ErrClientCertificateMissing)
+
+		authorizer, err = newAuthorizer(`{"allow": [{"cn": ["other"]}]}`)
+		So(err, ShouldBeNil)
+		So(authorizer.Authorize(newRequest(client)), ShouldEqual, ErrClientCertificateNotAllowed)
+
+		// without rules every verified certificate is allowed
+		authorizer, err = newAuthorizer(`{}`)
+		So(err, ShouldBeNil)
+		So(authorizer.Authorize(newRequest(client)), ShouldBeNil)
+	})
+
+	Convey("Test invalid rules", t, func() {
+		_, err := newAuthorizer(`{"allow": [null]}`)
+		So(err, ShouldNotBeNil)
+
+		_, err = newAuthorizer(`{"allow": [{}]}`)
+		So(err, ShouldNotBeNil)
+
+		_, err = newAuthorizer(`{"allow": [{"cn": []}]}`)
+		So(err, ShouldNotBeNil)
+
+		_, err = newAuthorizer(`{"allow": [{"cn": ["["]}]}`)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/phonkee/goexpose"
+)
+
+func init() {
+	registerCommand("apikey", "Generate api key and its line for keys file", apikeyCommand)
+}
+
+/*
+apikeyCommand runs apikey subcommands:
+
+	goexpose apikey generate -name deploy [-expires 2027-01-01] [-endpoints /deploy/*,/info]
+
+Key is printed on first line, line for keys file on second line. Key itself is
+not stored anywhere, so it must be handed out right away.
+*/
+func apikeyCommand(args []string) int {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s apikey generate -name <name> [-expires <date>] [-endpoints <patterns>]\n", os.Args[0])
+	}
+
+	if len(args) == 0 || args[0] != "generate" {
+		usage()
+		return 2
+	}
+
+	fs := flag.NewFlagSet("apikey generate", flag.ContinueOnError)
+	nameVar := fs.String("name", "", "Name of key")
+	expiresVar := fs.String("expires", "", "Expiration of key (RFC3339 time or date)")
+	endpointsVar := fs.String("endpoints", "", "Comma separated patterns of endpoint paths key is allowed for")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *nameVar == "" {
+		fmt.Fprintln(os.Stderr, "apikey: -name is required")
+		return 2
+	}
+
+	var (
+		expires   time.Time
+		endpoints []string
+		err       error
+	)
+	if *expiresVar != "" {
+		if expires, err = time.Parse(time.RFC3339, *expiresVar); err != nil {
+			if expires, err = time.Parse("2006-01-02", *expiresVar); err != nil {
+				fmt.Fprintf(os.Stderr, "apikey: invalid expires %s\n", *expiresVar)
+				return 2
+			}
+		}
+	}
+	if *endpointsVar != "" {
+		endpoints = strings.Split(*endpointsVar, ",")
+	}
+
+	key, entry, err := goexpose.GenerateAPIKey(*nameVar, expires, endpoints)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	fmt.Println(key)
+	fmt.Println(entry)
+	return 0
+}
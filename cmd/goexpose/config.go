@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ghodss/yaml"
+	"github.com/phonkee/goexpose"
+)
+
+func init() {
+	registerCommand("config", "Print effective configuration", configCommand)
+}
+
+/*
+configCommand runs config subcommands:
+
+	goexpose config dump -config config.json [-output-format yaml]
+
+Dump prints configuration with includes, endpoint groups, task templates and
+defaults applied. Encrypted values are printed encrypted.
+*/
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "dump" {
+		fmt.Fprintf(os.Stderr, "Usage: %s config dump [flags]\n", os.Args[0])
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config dump", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	outputFormat := fs.String("output-format", "json", "Output format (json, yaml)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *outputFormat != "json" && *outputFormat != "yaml" {
+		fmt.Fprintf(os.Stderr, "config: unsupported output format %s\n", *outputFormat)
+		return 2
+	}
+
+	config, err := loadConfig(cf)
+	if err != nil {
+		printErrors(err)
+		return 1
+	}
+
+	server, err := goexpose.NewServer(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return 1
+	}
+
+	// validation sets defaults
+	if err = server.Validate(); err != nil {
+		printErrors(err)
+		return 1
+	}
+
+	body, err := config.Dump()
+	if err == nil && *outputFormat == "yaml" {
+		body, err = yaml.JSONToYAML(body)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return 1
+	}
+
+	os.Stdout.Write(body)
+	if *outputFormat == "json" {
+		fmt.Println()
+	}
+	return 0
+}
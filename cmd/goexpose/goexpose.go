@@ -2,23 +2,65 @@
 Main package for goexpose binary.
 
 Goexpose provides several command line arguments such as:
-* config - configuration file
-* format - format of configuration file (json, yaml), default is json
+* config - configuration file or directory
+* format - format of configuration file (json, yaml, toml), default is detected by extension
+* secrets-key - key file to decrypt encrypted values in configuration
+
+Without command goexpose runs server, other commands are:
+* apikey generate - generate api key and its line for keys file
+* secrets - encrypt and decrypt values in configuration
+* config dump - print effective configuration
+* lint - find dangerous endpoint configurations
+* openapi - print OpenAPI document of endpoints
+* routes - print routes of endpoints and their conflicts
+* run - run endpoint without starting server
+* schema - print JSON Schema of configuration
+* validate - validate configuration without starting server
 
 */
 package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"sort"
 
 	"github.com/golang/glog"
 	"github.com/phonkee/goexpose"
 )
 
+/*
+command is goexpose subcommand, run returns exit code
+*/
+type command struct {
+	description string
+	run         func(args []string) int
+}
+
+var (
+	commands = map[string]command{}
+)
+
+/*
+registerCommand registers subcommand, it panics if command is already registered
+*/
+func registerCommand(name, description string, run func(args []string) int) {
+	if _, ok := commands[name]; ok {
+		panic(fmt.Sprintf("command %s already registered", name))
+	}
+	commands[name] = command{description: description, run: run}
+}
+
 func main() {
-	configVar := flag.String("config", "config.json", "Configuration file location")
-	formatVar := flag.String("format", "json", "Configuration file format. (json, yaml)")
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+
+	flag.Usage = usage
+	cf := addConfigFlags(flag.CommandLine)
 
 	// Parse command line flags
 	flag.Parse()
@@ -29,14 +71,8 @@ func main() {
 		err    error
 	)
 
-	// read config file
-	if config, err = goexpose.NewConfigFromFilename(*configVar, *formatVar); err != nil {
-		glog.Errorf("config error: %v", err)
-		os.Exit(1)
-	}
-
-	// change working directory to config directory
-	if err = os.Chdir(config.Directory); err != nil {
+	// read config file and change working directory to config directory
+	if config, err = loadConfig(cf); err != nil {
 		glog.Errorf("config error: %v", err)
 		os.Exit(1)
 	}
@@ -51,3 +87,54 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+/*
+usage prints usage of server and list of commands
+*/
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags]\n       %s <command> [flags]\n\nFlags:\n", os.Args[0], os.Args[0])
+	flag.PrintDefaults()
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(out, "\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-10s %s\n", name, commands[name].description)
+	}
+}
+
+/*
+configFlags are flags of commands that load configuration
+*/
+type configFlags struct {
+	config     *string
+	format     *string
+	secretsKey *string
+}
+
+/*
+addConfigFlags adds configuration flags to flag set
+*/
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	return &configFlags{
+		config:     fs.String("config", "config.json", "Configuration file or directory location"),
+		format:     fs.String("format", "", "Configuration file format (json, yaml, toml), detected by extension when not given"),
+		secretsKey: fs.String("secrets-key", "", "Key file to decrypt encrypted values in configuration"),
+	}
+}
+
+/*
+Load loads configuration by flags
+*/
+func (c *configFlags) Load() (*goexpose.Config, error) {
+	options := []goexpose.ConfigOption{}
+	if *c.secretsKey != "" {
+		options = append(options, goexpose.WithSecretsKeyFile(*c.secretsKey))
+	}
+	return goexpose.NewConfigFromFilename(*c.config, *c.format, options...)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/phonkee/goexpose"
+)
+
+func init() {
+	registerCommand("lint", "Find dangerous endpoint configurations", lintCommand)
+}
+
+/*
+lintCommand prints findings of security linter:
+
+	goexpose lint -config config.json [-severity error]
+
+Only findings with given or higher severity are printed, exit code is 1 when
+some finding is printed (or configuration is invalid).
+*/
+func lintCommand(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	severity := fs.String("severity", goexpose.LINT_WARNING, "Minimum severity of printed findings (warning, error)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *severity != goexpose.LINT_WARNING && *severity != goexpose.LINT_ERROR {
+		fmt.Fprintf(os.Stderr, "lint: unknown severity %s\n", *severity)
+		return 2
+	}
+
+	config, err := loadConfig(cf)
+	if err != nil {
+		printErrors(err)
+		return 1
+	}
+
+	server, err := goexpose.NewServer(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lint: %v\n", err)
+		return 1
+	}
+
+	findings, err := server.Lint()
+	if err != nil {
+		printErrors(err)
+		return 1
+	}
+
+	result := 0
+	for _, finding := range findings {
+		if *severity == goexpose.LINT_ERROR && finding.Severity != goexpose.LINT_ERROR {
+			continue
+		}
+		fmt.Println(finding)
+		result = 1
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/phonkee/goexpose"
+)
+
+func init() {
+	registerCommand("openapi", "Print OpenAPI document of endpoints", openapiCommand)
+}
+
+/*
+openapiCommand prints OpenAPI document generated from configuration to stdout
+or to file given by -output.
+*/
+func openapiCommand(args []string) int {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	output := fs.String("output", "", "Write document to file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := loadConfig(cf)
+	if err != nil {
+		printErrors(err)
+		return 1
+	}
+
+	server, err := goexpose.NewServer(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "openapi: %v\n", err)
+		return 1
+	}
+
+	document, err := server.OpenAPI()
+	if err != nil {
+		printErrors(err)
+		return 1
+	}
+
+	body, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "openapi: %v\n", err)
+		return 1
+	}
+	body = append(body, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(body)
+		return 0
+	}
+
+	if err = ioutil.WriteFile(*output, body, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "openapi: %v\n", err)
+		return 1
+	}
+	return 0
+}
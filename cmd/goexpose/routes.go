@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/phonkee/goexpose"
+)
+
+func init() {
+	registerCommand("routes", "Print routes of endpoints and their conflicts", routesCommand)
+}
+
+/*
+routesCommand prints routes in order they are matched:
+
+	goexpose routes -config config.json
+
+Routes shadowed by or overlapping with earlier routes are printed as warnings
+to stderr. Exit code is 1 when some route is shadowed (it is never matched).
+*/
+func routesCommand(args []string) int {
+	fs := flag.NewFlagSet("routes", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	asJSON := fs.Bool("json", false, "Print routes as json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := loadConfig(cf)
+	if err != nil {
+		printErrors(err)
+		return 1
+	}
+
+	server, err := goexpose.NewServer(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "routes: %v\n", err)
+		return 1
+	}
+
+	routes, err := server.Routes()
+	if err != nil {
+		printErrors(err)
+		return 1
+	}
+	conflicts := goexpose.RouteConflicts(routes)
+
+	if *asJSON {
+		body, err := json.MarshalIndent(map[string]interface{}{
+			"routes":    routes,
+			"conflicts": conflicts,
+		}, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "routes: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(body))
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "METHOD\tPATH\tTYPE\tAUTHORIZERS\tNAME\tSOURCE")
+		for _, route := range routes {
+			authorizers := strings.Join(route.Authorizers, ",")
+			if authorizers == "" {
+				authorizers = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", route.Method, route.Path, route.Type, authorizers, route.Name, route.Source)
+		}
+		w.Flush()
+	}
+
+	result := 0
+	for _, conflict := range conflicts {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", conflict)
+		if conflict.Shadowed {
+			result = 1
+		}
+	}
+	return result
+}
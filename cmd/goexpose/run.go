@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/phonkee/goexpose"
+)
+
+func init() {
+	registerCommand("run", "Run endpoint without starting server", runCommand)
+}
+
+/*
+headerFlags are repeatable -header flags ("Name: value")
+*/
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("invalid header %s, expected \"Name: value\"", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+/*
+runCommand runs endpoint without listener and prints response:
+
+	goexpose run -config config.json GET /postgres/12?limit=5
+
+Request body is read from stdin (when stdin is not terminal). Exit code is 0
+for 2xx and 3xx responses, 4 for 4xx responses and 5 for 5xx responses.
+*/
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	skipAuthorizers := fs.Bool("skip-authorizers", false, "Do not run authorizers of endpoint")
+	headers := &headerFlags{}
+	fs.Var(headers, "header", "Request header \"Name: value\" (can be repeated)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s run [flags] METHOD PATH\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	var (
+		body []byte
+		err  error
+	)
+	if body, err = readStdin(); err != nil {
+		fmt.Fprintf(os.Stderr, "run: %v\n", err)
+		return 1
+	}
+
+	var request *http.Request
+	if request, err = http.NewRequest(strings.ToUpper(fs.Arg(0)), fs.Arg(1), bytes.NewReader(body)); err != nil {
+		fmt.Fprintf(os.Stderr, "run: %v\n", err)
+		return 2
+	}
+	for _, header := range *headers {
+		parts := strings.SplitN(header, ":", 2)
+		request.Header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+
+	config, err := loadConfig(cf)
+	if err != nil {
+		printErrors(err)
+		return 1
+	}
+
+	server, err := goexpose.NewServer(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run: %v\n", err)
+		return 1
+	}
+
+	response, err := server.RunRequest(request, *skipAuthorizers)
+	if err != nil {
+		printErrors(err)
+		return 1
+	}
+
+	os.Stdout.Write(response.Body.Bytes())
+
+	switch {
+	case response.Code >= http.StatusInternalServerError:
+		return 5
+	case response.Code >= http.StatusBadRequest:
+		return 4
+	}
+	return 0
+}
+
+/*
+readStdin returns content of stdin, terminal is not read
+*/
+func readStdin() ([]byte, error) {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeCharDevice != 0 {
+		return nil, nil
+	}
+	return ioutil.ReadAll(os.Stdin)
+}
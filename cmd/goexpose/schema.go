@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/phonkee/goexpose"
+)
+
+func init() {
+	registerCommand("schema", "Print JSON Schema of configuration", schemaCommand)
+}
+
+/*
+schemaCommand prints JSON Schema of configuration with all registered tasks,
+authorizers and connections to stdout or to file given by -output.
+*/
+func schemaCommand(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	output := fs.String("output", "", "Write schema to file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	body, err := json.MarshalIndent(goexpose.ConfigSchema(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "schema: %v\n", err)
+		return 1
+	}
+	body = append(body, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(body)
+		return 0
+	}
+
+	if err = ioutil.WriteFile(*output, body, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "schema: %v\n", err)
+		return 1
+	}
+	return 0
+}
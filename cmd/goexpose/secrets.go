@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/phonkee/goexpose"
+)
+
+func init() {
+	registerCommand("secrets", "Generate secrets key, encrypt and decrypt configuration values", secretsCommand)
+}
+
+/*
+secretsCommand runs secrets subcommands:
+
+	goexpose secrets keygen -key secrets.key
+	goexpose secrets encrypt -key secrets.key [value]
+	goexpose secrets decrypt -key secrets.key [value]
+
+When value is not given it is read from stdin (trailing newline is removed).
+*/
+func secretsCommand(args []string) int {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s secrets keygen|encrypt|decrypt -key <file> [value]\n", os.Args[0])
+	}
+
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	fs := flag.NewFlagSet("secrets "+args[0], flag.ContinueOnError)
+	keyVar := fs.String("key", "", "Secrets key file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *keyVar == "" {
+		fmt.Fprintln(os.Stderr, "secrets: -key is required")
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "keygen":
+		err = secretsKeygen(*keyVar)
+	case "encrypt", "decrypt":
+		err = secretsCrypt(args[0], *keyVar, fs.Args())
+	default:
+		usage()
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "secrets: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+/*
+secretsKeygen writes new key to key file, existing file is never overwritten
+*/
+func secretsKeygen(filename string) (err error) {
+	var key string
+	if key, err = goexpose.GenerateSecretsKey(); err != nil {
+		return
+	}
+
+	var file *os.File
+	if file, err = os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); err != nil {
+		return
+	}
+	if _, err = fmt.Fprintln(file, key); err != nil {
+		file.Close()
+		return
+	}
+	return file.Close()
+}
+
+/*
+secretsCrypt encrypts or decrypts value from arguments or stdin and prints result
+*/
+func secretsCrypt(action, filename string, args []string) (err error) {
+	var key []byte
+	if key, err = goexpose.ReadSecretsKey(filename); err != nil {
+		return
+	}
+
+	var value string
+	if len(args) > 0 {
+		value = strings.Join(args, " ")
+	} else {
+		var body []byte
+		if body, err = ioutil.ReadAll(os.Stdin); err != nil {
+			return
+		}
+		value = strings.TrimRight(string(body), "\r\n")
+	}
+
+	var result string
+	if action == "encrypt" {
+		result, err = goexpose.EncryptSecret(key, value)
+	} else {
+		result, err = goexpose.DecryptSecret(key, strings.TrimSpace(value))
+	}
+	if err != nil {
+		return
+	}
+
+	fmt.Println(result)
+	return
+}
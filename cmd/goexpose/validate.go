@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/phonkee/goexpose"
+)
+
+func init() {
+	registerCommand("validate", "Validate configuration without starting server", validateCommand)
+}
+
+/*
+validateCommand loads configuration and runs all task and authorizer factories.
+All errors are printed, exit code is 1 when configuration is invalid.
+*/
+func validateCommand(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := loadConfig(cf)
+	if err == nil {
+		var server *goexpose.Server
+		if server, err = goexpose.NewServer(config); err == nil {
+			err = server.Validate()
+		}
+	}
+
+	if err != nil {
+		printErrors(err)
+		return 1
+	}
+
+	fmt.Printf("configuration %s is valid\n", config.Filename)
+	return 0
+}
+
+/*
+loadConfig loads configuration and changes working directory to configuration
+directory, so relative paths in configuration work.
+*/
+func loadConfig(cf *configFlags) (config *goexpose.Config, err error) {
+	if config, err = cf.Load(); err != nil {
+		return
+	}
+	err = os.Chdir(config.Directory)
+	return
+}
+
+/*
+printErrors prints configuration errors, one per line
+*/
+func printErrors(err error) {
+	if errs, ok := err.(goexpose.ConfigErrors); ok {
+		for _, e := range errs {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		return
+	}
+	fmt.Fprintln(os.Stderr, err)
+}
@@ -5,15 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/ghodss/yaml"
-	"errors"
 )
 
 type unmarshalFunc func([]byte, interface{}) error
@@ -21,72 +22,141 @@ type unmarshalFunc func([]byte, interface{}) error
 var (
 	configFormats     map[string]unmarshalFunc
 	configFormatsLock *sync.RWMutex
+
+	// configuration formats by file extension
+	configExtensions map[string]string
 )
 
 func init() {
 
-	// prepare configuration formats (currently json, yaml)
+	// prepare configuration formats (currently json, yaml, toml)
 	configFormats = map[string]unmarshalFunc{}
 	configFormatsLock = &sync.RWMutex{}
+	configExtensions = map[string]string{}
 
-	func() {
-		configFormatsLock.Lock()
-		defer configFormatsLock.Unlock()
-		configFormats["json"] = json.Unmarshal
+	RegisterConfigFormat("json", json.Unmarshal, ".json")
 
-		// custom yaml unmarshal, since when used directly it panics.
-		// so we just convert yaml to json and call json unmarshal
-		configFormats["yaml"] = func(body []byte, target interface{}) (err error){
-			if response, e := yaml.YAMLToJSON(body); e != nil {
-				err = e
-			} else {
-				err = json.Unmarshal(response, target)
-			}
+	// custom yaml unmarshal, since when used directly it panics.
+	// so we just convert yaml to json and call json unmarshal
+	RegisterConfigFormat("yaml", func(body []byte, target interface{}) (err error) {
+		if response, e := yaml.YAMLToJSON(body); e != nil {
+			err = e
+		} else {
+			err = json.Unmarshal(response, target)
+		}
+
+		return
+	}, ".yaml", ".yml")
 
+	// toml is decoded to map and converted to json as well, so json tags are used
+	RegisterConfigFormat("toml", func(body []byte, target interface{}) (err error) {
+		document := map[string]interface{}{}
+		if _, err = toml.Decode(string(body), &document); err != nil {
 			return
 		}
-	}()
+
+		var response []byte
+		if response, err = json.Marshal(plainTOML(document)); err != nil {
+			return
+		}
+		return json.Unmarshal(response, target)
+	}, ".toml")
+}
+
+/*
+RegisterConfigFormat registers configuration format and file extensions that
+are detected as this format (e.g. ".json"). Programs that embed goexpose can
+register additional formats. It panics if format or extension is already
+registered.
+*/
+func RegisterConfigFormat(name string, unmarshal func(body []byte, target interface{}) error, extensions ...string) {
+	configFormatsLock.Lock()
+	defer configFormatsLock.Unlock()
+
+	if _, ok := configFormats[name]; ok {
+		panic(fmt.Sprintf("config format %s already registered", name))
+	}
+	for _, extension := range extensions {
+		if other, ok := configExtensions[strings.ToLower(extension)]; ok {
+			panic(fmt.Sprintf("config extension %s already registered by %s", extension, other))
+		}
+	}
+
+	configFormats[name] = unmarshal
+	for _, extension := range extensions {
+		configExtensions[strings.ToLower(extension)] = name
+	}
+}
+
+/*
+formatByExtension returns configuration format by file extension, if extension
+is unknown given default format is returned.
+*/
+func formatByExtension(filename, format string) string {
+	configFormatsLock.RLock()
+	defer configFormatsLock.RUnlock()
+
+	if result, ok := configExtensions[strings.ToLower(filepath.Ext(filename))]; ok {
+		return result
+	}
+	return format
 }
 
 /*
-Returns filename from file
+ConfigOption changes how configuration is loaded
 */
-func NewConfigFromFilename(filename, format string) (config *Config, err error) {
+type ConfigOption func(l *configLoader) error
+
+/*
+Returns config from file. If filename is directory, all configuration files in
+directory are merged (conf.d mode). Configuration can include other files by
+"include" directive (see include.go). If format is empty, it is detected by file
+extension (json when extension is unknown).
+*/
+func NewConfigFromFilename(filename, format string, options ...ConfigOption) (config *Config, err error) {
 	config = NewConfig()
+	config.options = options
 
-	var (
-		result []byte
-	)
-	if result, err = ioutil.ReadFile(filename); err != nil {
+	// remember where config came from so it can be reloaded
+	if config.Filename, err = filepath.Abs(filename); err != nil {
 		return
 	}
+	config.Format = format
 
-	found := false
-	for name, fmtUnmarshalFunc := range configFormats {
-		if name == format {
-			if err = fmtUnmarshalFunc(result, config); err != nil {
-				return
-			}
-			found = true
-			break
-		}
+	var info os.FileInfo
+	if info, err = os.Stat(config.Filename); err != nil {
+		return
 	}
 
-	if !found {
-		err = errors.New("file format not found")
-		return
+	// included files with unknown extensions use format of main file
+	if format == "" {
+		format = formatByExtension(config.Filename, "json")
 	}
 
-	// unmarshal config
-	//if err = json.Unmarshal(result, config); err != nil {
-	//	return
-	//}
+	loader := newConfigLoader(config, format)
+	for _, option := range options {
+		if err = option(loader); err != nil {
+			return
+		}
+	}
 
-	// get config dir
-	if config.Directory, err = filepath.Abs(filepath.Dir(filename)); err != nil {
+	if info.IsDir() {
+		config.Directory = config.Filename
+		err = loader.LoadDirectory(config.Directory)
+	} else {
+		// get config dir
+		config.Directory = filepath.Dir(config.Filename)
+		err = loader.LoadFile(config.Filename, format)
+	}
+	if err != nil {
 		return
 	}
 
+	config.Files = loader.files
+	config.settingFiles = loader.settings
+
+	// tasks extend templates from all loaded files
+	err = config.applyTaskTemplates()
 	return
 }
 
@@ -95,8 +165,9 @@ Returns config with default values
 */
 func NewConfig() *Config {
 	return &Config{
-		Host: "0.0.0.0",
-		Port: 9980,
+		Host:         "0.0.0.0",
+		Port:         9980,
+		DrainTimeout: Duration(DEFAULT_DRAIN_TIMEOUT),
 	}
 }
 
@@ -107,11 +178,141 @@ type Config struct {
 	Host        string                       `json:"host"`
 	Port        int                          `json:"port"`
 	SSL         *SSLConfig                   `json:"ssl"`
+	Listeners   []*ListenerConfig            `json:"listeners"`
 	PrettyJson  bool                         `json:"pretty_json"`
 	Authorizers map[string]*AuthorizerConfig `json:"authorizers"`
+	Connections map[string]*ConnectionConfig `json:"connections"`
 	Endpoints   []*EndpointConfig            `json:"endpoints"`
 	ReloadEnv   bool                         `json:"reload_env"`
-	Directory   string                       `json:"-"`
+
+	// how long to wait for running tasks on shutdown before they are cancelled
+	DrainTimeout Duration `json:"drain_timeout"`
+
+	// configuration reloading
+	Reload *ReloadConfig `json:"reload"`
+
+	// prometheus metrics endpoint
+	Metrics *MetricsConfig `json:"metrics"`
+
+	// liveness and readiness endpoints
+	Health *HealthConfig `json:"health"`
+
+	// logging configuration, if not given glog is used
+	Logging *LoggingConfig `json:"logging"`
+
+	// exporting of spans
+	Tracing *TracingConfig `json:"tracing"`
+
+	// openapi document endpoint
+	OpenAPI *OpenAPIConfig `json:"openapi"`
+
+	// security linting at startup
+	Lint *LintConfig `json:"lint"`
+
+	// files included to this configuration (globs relative to directory)
+	Include []string `json:"include"`
+
+	// named task configs that tasks can extend
+	TaskTemplates map[string]*TaskConfig `json:"task_templates"`
+
+	// endpoints with shared path prefix, authorizers, query params and type
+	Groups []*EndpointGroupConfig `json:"groups"`
+
+	Directory string `json:"-"`
+	Filename  string `json:"-"`
+	Format    string `json:"-"`
+
+	// all loaded files and watched directories
+	Files []string `json:"-"`
+
+	// options used to load configuration, used again on reload
+	options []ConfigOption
+
+	// files that define top level settings
+	settingFiles map[string]string
+
+	// encrypted values and values with variables by resolved value
+	secrets map[string]string
+}
+
+/*
+settingSource returns location of top level setting
+*/
+func (c *Config) settingSource(name string) configSource {
+	return configSource{File: c.settingFiles[name], Pointer: "/" + name}
+}
+
+/*
+endpointSource returns location of endpoint, endpoints that were not loaded from
+file are identified by index.
+*/
+func (c *Config) endpointSource(index int) configSource {
+	if ec := c.Endpoints[index]; ec.source.Pointer != "" {
+		return ec.source
+	}
+	return configSource{Pointer: fmt.Sprintf("/endpoints/%d", index)}
+}
+
+/*
+authorizerSource returns location of authorizer
+*/
+func (c *Config) authorizerSource(name string) configSource {
+	if ac := c.Authorizers[name]; ac != nil && ac.source.Pointer != "" {
+		return ac.source
+	}
+	return configSource{Pointer: "/authorizers/" + escapePointer(name)}
+}
+
+/*
+ReloadConfig configures reloading of configuration when file changes.
+Configuration is always reloaded on SIGHUP.
+*/
+type ReloadConfig struct {
+	// watch configuration file for changes
+	Watch bool `json:"watch"`
+
+	// how often to check file for changes
+	Interval Duration `json:"interval"`
+}
+
+/*
+Duration is time.Duration that can be unmarshalled from string ("30s", "1m")
+or from number which means seconds.
+*/
+type Duration time.Duration
+
+/*
+UnmarshalJSON unmarshals duration from string or number of seconds
+*/
+func (d *Duration) UnmarshalJSON(body []byte) (err error) {
+	var value interface{}
+	if err = json.Unmarshal(body, &value); err != nil {
+		return
+	}
+
+	switch t := value.(type) {
+	case float64:
+		*d = Duration(t * float64(time.Second))
+	case string:
+		var parsed time.Duration
+		if parsed, err = time.ParseDuration(strings.TrimSpace(t)); err != nil {
+			return fmt.Errorf("invalid duration %v", t)
+		}
+		*d = Duration(parsed)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration %v", t)
+	}
+
+	return
+}
+
+/*
+MarshalJSON marshals duration as string
+*/
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
 }
 
 /*
@@ -120,6 +321,21 @@ SSL config
 type SSLConfig struct {
 	Cert string `json:"cert"`
 	Key  string `json:"key"`
+
+	// client certificates, list of ca bundle files
+	ClientCA []string `json:"client_ca"`
+
+	// client certificate verification: none (default), optional, required
+	ClientAuth string `json:"client_auth"`
+
+	// minimum tls version: 1.0, 1.1, 1.2 (default), 1.3
+	MinVersion string `json:"min_version"`
+
+	// cipher suites names (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+	Ciphers []string `json:"ciphers"`
+
+	// how often to check cert and key files for changes
+	ReloadInterval Duration `json:"reload_interval"`
 }
 
 /*
@@ -131,6 +347,10 @@ type TaskConfig struct {
 	Config      json.RawMessage `json:"config"`
 	QueryParams *QueryParams    `json:"query_params"`
 	Description string          `json:"description"`
+	Timeout     Duration        `json:"timeout"`
+
+	// name of task template this task extends
+	Extends string `json:"extends"`
 }
 
 type EndpointConfig struct {
@@ -140,10 +360,20 @@ type EndpointConfig struct {
 	Type        string                `json:"type"`
 	QueryParams *QueryParams          `json:"query_params"`
 	RawResponse bool                  `json:"raw_response"`
+
+	// default timeout for tasks
+	Timeout Duration `json:"timeout"`
+
+	// where endpoint was defined
+	source configSource
 }
 
 func (e *EndpointConfig) Validate() (err error) {
 
+	if e.Timeout < 0 {
+		return fmt.Errorf("invalid timeout")
+	}
+
 	if e.QueryParams != nil {
 		if err = e.QueryParams.Validate(); err != nil {
 			return
@@ -153,9 +383,10 @@ func (e *EndpointConfig) Validate() (err error) {
 	// set type to unset tasks
 	e.Type = strings.TrimSpace(e.Type)
 	if e.Type != "" {
-		for _, tc := range e.Methods {
+		for method, tc := range e.Methods {
 			if tc.Type == "" {
 				tc.Type = e.Type
+				e.Methods[method] = tc
 			}
 		}
 	}
@@ -179,6 +410,10 @@ func (t *TaskConfig) Validate() (err error) {
 		return fmt.Errorf("Invalid task type")
 	}
 
+	if t.Timeout < 0 {
+		return fmt.Errorf("Invalid task timeout")
+	}
+
 	if t.QueryParams != nil {
 		if err = t.QueryParams.Validate(); err != nil {
 			return
@@ -194,6 +429,9 @@ Configuration for authorizer
 type AuthorizerConfig struct {
 	Type   string          `json:"type"`
 	Config json.RawMessage `json:"config"`
+
+	// where authorizer was defined
+	source configSource
 }
 
 /*
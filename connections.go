@@ -0,0 +1,713 @@
+package goexpose
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/gocql/gocql"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrConnectionNotFound = errors.New("connection not found")
+	ErrConnectionClosed   = errors.New("connection is closed")
+)
+
+func init() {
+	RegisterConnectionFactory("postgres", SQLConnectionFactory)
+	RegisterConnectionFactory("mysql", SQLConnectionFactory)
+	RegisterConnectionFactory("redis", RedisConnectionFactory)
+	RegisterConnectionFactory("cassandra", CassandraConnectionFactory)
+
+	RegisterConnectionSchema("postgres", SQLConnectionConfig{})
+	RegisterConnectionSchema("mysql", SQLConnectionConfig{})
+	RegisterConnectionSchema("redis", RedisConnectionConfig{})
+	RegisterConnectionSchema("cassandra", CassandraConnectionConfig{})
+}
+
+/*
+Connection is named shared connection (pool) defined in configuration
+*/
+type Connection interface {
+	// Ping checks whether backend is available
+	Ping(ctx context.Context) error
+
+	// Close closes all connections
+	Close() error
+}
+
+/*
+ConnectionFactory creates connection from configuration. Factory should not
+connect, connection is created lazily on first use.
+*/
+type ConnectionFactory func(config *ConnectionConfig) (Connection, error)
+
+var (
+	connectionfactories     = map[string]ConnectionFactory{}
+	connectionfactorieslock = &sync.RWMutex{}
+)
+
+/*
+RegisterConnectionFactory registers factory for given connection type
+*/
+func RegisterConnectionFactory(id string, factory ConnectionFactory) {
+	connectionfactorieslock.Lock()
+	defer connectionfactorieslock.Unlock()
+
+	if _, ok := connectionfactories[id]; ok {
+		panic(fmt.Sprintf("connection factory %s already registered", id))
+	}
+	connectionfactories[id] = factory
+}
+
+/*
+getConnectionFactory returns connection factory by type
+*/
+func getConnectionFactory(id string) (factory ConnectionFactory, ok bool) {
+	connectionfactorieslock.RLock()
+	defer connectionfactorieslock.RUnlock()
+
+	factory, ok = connectionfactories[id]
+	return
+}
+
+/*
+ConnectionConfig is configuration of named connection
+*/
+type ConnectionConfig struct {
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config"`
+
+	// maximum number of open connections (per host for cassandra)
+	PoolSize int `json:"pool_size"`
+
+	// maximum number of idle connections
+	MaxIdle int `json:"max_idle"`
+
+	// idle connections are closed after this time
+	IdleTimeout Duration `json:"idle_timeout"`
+
+	// connections are closed after they are this old
+	MaxLifetime Duration `json:"max_lifetime"`
+
+	// how often to check health of connection
+	HealthCheck Duration `json:"health_check"`
+}
+
+/*
+Validate validates connection config
+*/
+func (c *ConnectionConfig) Validate() (err error) {
+	c.Type = strings.TrimSpace(c.Type)
+	if _, ok := getConnectionFactory(c.Type); !ok {
+		return fmt.Errorf("connection type %s does not exist", c.Type)
+	}
+
+	if c.PoolSize < 0 || c.MaxIdle < 0 {
+		return errors.New("pool_size and max_idle cannot be negative")
+	}
+
+	if c.IdleTimeout < 0 || c.MaxLifetime < 0 || c.HealthCheck < 0 {
+		return errors.New("idle_timeout, max_lifetime and health_check cannot be negative")
+	}
+
+	return
+}
+
+/*
+CheckConnection checks that connection with given name exists in config and has given type.
+It's used by task factories to validate configuration.
+*/
+func (c *Config) CheckConnection(name string, types ...string) (err error) {
+	cc, ok := c.Connections[name]
+	if !ok {
+		return fmt.Errorf("connection `%s` does not exist", name)
+	}
+
+	for _, t := range types {
+		if cc.Type == t {
+			return
+		}
+	}
+
+	return fmt.Errorf("connection `%s` has type %s, expected %s", name, cc.Type, strings.Join(types, ", "))
+}
+
+/*
+newConnections returns manager of named connections
+*/
+func newConnections() *Connections {
+	return &Connections{
+		items: map[string]*namedConnection{},
+	}
+}
+
+/*
+Connections manages named connections. Connections are created lazily on first
+use, health checked periodically and closed on shutdown.
+*/
+type Connections struct {
+	lock  sync.RWMutex
+	items map[string]*namedConnection
+}
+
+/*
+namedConnection holds lazily created connection
+*/
+type namedConnection struct {
+	name   string
+	config *ConnectionConfig
+
+	lock       sync.Mutex
+	connection Connection
+	closed     bool
+
+	// health check state
+	healthy   bool
+	lastError string
+	lastCheck time.Time
+	stop      chan struct{}
+}
+
+/*
+Update updates connections by configuration. Unchanged connections are kept,
+changed and removed connections are closed.
+*/
+func (c *Connections) Update(configs map[string]*ConnectionConfig) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for name, item := range c.items {
+		if config, ok := configs[name]; ok && reflect.DeepEqual(config, item.config) {
+			continue
+		}
+		delete(c.items, name)
+		item.Close()
+	}
+
+	for name, config := range configs {
+		if _, ok := c.items[name]; ok {
+			continue
+		}
+		c.items[name] = &namedConnection{
+			name:   name,
+			config: config,
+		}
+	}
+}
+
+/*
+Get returns connection by name, connection is created on first call.
+*/
+func (c *Connections) Get(name string) (connection Connection, err error) {
+	c.lock.RLock()
+	item, ok := c.items[name]
+	c.lock.RUnlock()
+
+	if !ok {
+		return nil, ErrConnectionNotFound
+	}
+
+	return item.Get()
+}
+
+/*
+SQL returns sqlx database for connection
+*/
+func (c *Connections) SQL(name string) (db *sqlx.DB, err error) {
+	var connection Connection
+	if connection, err = c.Get(name); err != nil {
+		return
+	}
+
+	sc, ok := connection.(*SQLConnection)
+	if !ok {
+		return nil, fmt.Errorf("connection %s is not sql connection", name)
+	}
+	return sc.DB, nil
+}
+
+/*
+Redis returns redis connection
+*/
+func (c *Connections) Redis(name string) (result *RedisConnection, err error) {
+	var connection Connection
+	if connection, err = c.Get(name); err != nil {
+		return
+	}
+
+	var ok bool
+	if result, ok = connection.(*RedisConnection); !ok {
+		return nil, fmt.Errorf("connection %s is not redis connection", name)
+	}
+	return
+}
+
+/*
+Cassandra returns cassandra connection
+*/
+func (c *Connections) Cassandra(name string) (result *CassandraConnection, err error) {
+	var connection Connection
+	if connection, err = c.Get(name); err != nil {
+		return
+	}
+
+	var ok bool
+	if result, ok = connection.(*CassandraConnection); !ok {
+		return nil, fmt.Errorf("connection %s is not cassandra connection", name)
+	}
+	return
+}
+
+/*
+ConnectionStatus is health state of named connection
+*/
+type ConnectionStatus struct {
+	Name      string     `json:"name"`
+	Type      string     `json:"type"`
+	Open      bool       `json:"open"`
+	Healthy   bool       `json:"healthy"`
+	LastError string     `json:"last_error,omitempty"`
+	LastCheck *time.Time `json:"last_check,omitempty"`
+}
+
+/*
+Status returns health state of all connections
+*/
+func (c *Connections) Status() []ConnectionStatus {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	result := make([]ConnectionStatus, 0, len(c.items))
+	for _, item := range c.items {
+		result = append(result, item.Status())
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+/*
+Close closes all connections
+*/
+func (c *Connections) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for name, item := range c.items {
+		item.Close()
+		delete(c.items, name)
+	}
+}
+
+/*
+Get returns connection, creates it if it wasn't created yet
+*/
+func (n *namedConnection) Get() (connection Connection, err error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.closed {
+		return nil, ErrConnectionClosed
+	}
+
+	if n.connection != nil {
+		return n.connection, nil
+	}
+
+	factory, ok := getConnectionFactory(n.config.Type)
+	if !ok {
+		return nil, fmt.Errorf("connection type %s does not exist", n.config.Type)
+	}
+
+	if n.connection, err = factory(n.config); err != nil {
+		return
+	}
+
+	logger().Debugf("Connection %s (%s) created", n.name, n.config.Type)
+
+	// start health checks
+	n.healthy = true
+	if n.config.HealthCheck > 0 {
+		n.stop = make(chan struct{})
+		go n.healthCheck(n.connection, time.Duration(n.config.HealthCheck), n.stop)
+	}
+
+	return n.connection, nil
+}
+
+/*
+Ping pings connection and stores result
+*/
+func (n *namedConnection) Ping(ctx context.Context) (err error) {
+	var connection Connection
+	if connection, err = n.Get(); err != nil {
+		return
+	}
+
+	err = connection.Ping(ctx)
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.lastCheck = time.Now()
+	n.healthy = err == nil
+	n.lastError = ""
+	if err != nil {
+		n.lastError = err.Error()
+	}
+
+	return
+}
+
+/*
+healthCheck periodically pings connection
+*/
+func (n *namedConnection) healthCheck(connection Connection, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := n.Ping(ctx); err != nil {
+				logger().Warningf("Connection %s health check failed: %v", n.name, err)
+			}
+			cancel()
+		case <-stop:
+			return
+		}
+	}
+}
+
+/*
+Status returns health state of connection
+*/
+func (n *namedConnection) Status() (result ConnectionStatus) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	result = ConnectionStatus{
+		Name:      n.name,
+		Type:      n.config.Type,
+		Open:      n.connection != nil,
+		Healthy:   n.healthy,
+		LastError: n.lastError,
+	}
+	if !n.lastCheck.IsZero() {
+		check := n.lastCheck
+		result.LastCheck = &check
+	}
+	return
+}
+
+/*
+Close closes connection
+*/
+func (n *namedConnection) Close() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.closed = true
+	if n.stop != nil {
+		close(n.stop)
+		n.stop = nil
+	}
+
+	if n.connection == nil {
+		return
+	}
+
+	if err := n.connection.Close(); err != nil {
+		logger().Warningf("Closing connection %s returned error: %v", n.name, err)
+	} else {
+		logger().Debugf("Connection %s closed", n.name)
+	}
+	n.connection = nil
+}
+
+/*
+SQL connection (postgres, mysql)
+*/
+
+/*
+SQLConnectionConfig is config for sql connection
+*/
+type SQLConnectionConfig struct {
+	URL string `json:"url"`
+}
+
+/*
+SQLConnectionFactory creates sql connection pool, sql.DB connects lazily.
+*/
+func SQLConnectionFactory(config *ConnectionConfig) (result Connection, err error) {
+	sc := &SQLConnectionConfig{}
+	if err = decodeConfig(config.Config, sc); err != nil {
+		return
+	}
+
+	if sc.URL = strings.TrimSpace(sc.URL); sc.URL == "" {
+		return nil, errors.New("please provide url")
+	}
+
+	var db *sqlx.DB
+	if db, err = sqlx.Open(config.Type, sc.URL); err != nil {
+		return
+	}
+
+	db.SetMaxOpenConns(config.PoolSize)
+	if config.MaxIdle > 0 {
+		db.SetMaxIdleConns(config.MaxIdle)
+	}
+	db.SetConnMaxIdleTime(time.Duration(config.IdleTimeout))
+	db.SetConnMaxLifetime(time.Duration(config.MaxLifetime))
+
+	result = &SQLConnection{
+		DB: db,
+	}
+	return
+}
+
+/*
+SQLConnection is pooled sql connection
+*/
+type SQLConnection struct {
+	DB *sqlx.DB
+}
+
+/*
+Ping pings database
+*/
+func (s *SQLConnection) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+/*
+Close closes database
+*/
+func (s *SQLConnection) Close() error {
+	return s.DB.Close()
+}
+
+/*
+Redis connection
+*/
+
+/*
+RedisConnectionConfig is config for redis connection
+*/
+type RedisConnectionConfig struct {
+	Address  string `json:"address"`
+	Network  string `json:"network"`
+	Database int    `json:"database"`
+	Password string `json:"password"`
+}
+
+/*
+RedisConnectionFactory creates redis connection pool
+*/
+func RedisConnectionFactory(config *ConnectionConfig) (result Connection, err error) {
+	rc := &RedisConnectionConfig{
+		Address: ":6379",
+		Network: "tcp",
+	}
+	if err = decodeConfig(config.Config, rc); err != nil {
+		return
+	}
+
+	lifetime := time.Duration(config.MaxLifetime)
+
+	pool := &redis.Pool{
+		MaxIdle:     config.MaxIdle,
+		MaxActive:   config.PoolSize,
+		IdleTimeout: time.Duration(config.IdleTimeout),
+		Wait:        true,
+		Dial: func() (conn redis.Conn, err error) {
+			options := []redis.DialOption{redis.DialDatabase(rc.Database)}
+			if rc.Password != "" {
+				options = append(options, redis.DialPassword(rc.Password))
+			}
+			if conn, err = redis.Dial(rc.Network, rc.Address, options...); err != nil {
+				return
+			}
+			return &redisPoolConn{Conn: conn, created: time.Now()}, nil
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) (err error) {
+			if pc, ok := c.(*redisPoolConn); ok && lifetime > 0 && time.Since(pc.created) > lifetime {
+				return errors.New("connection max lifetime exceeded")
+			}
+			if time.Since(t) > time.Minute {
+				_, err = c.Do("PING")
+			}
+			return
+		},
+	}
+
+	if pool.MaxIdle == 0 {
+		pool.MaxIdle = DEFAULT_REDIS_MAX_IDLE
+	}
+
+	result = &RedisConnection{
+		Pool: pool,
+	}
+	return
+}
+
+/*
+redisPoolConn remembers when connection was created (redigo pool doesn't support max lifetime)
+*/
+type redisPoolConn struct {
+	redis.Conn
+	created time.Time
+}
+
+/*
+RedisConnection is pooled redis connection
+*/
+type RedisConnection struct {
+	Pool *redis.Pool
+}
+
+/*
+GetContext returns connection from pool, it waits for free connection until context is done.
+*/
+func (r *RedisConnection) GetContext(ctx context.Context) (conn redis.Conn, err error) {
+	result := make(chan redis.Conn, 1)
+	go func() {
+		result <- r.Pool.Get()
+	}()
+
+	select {
+	case conn = <-result:
+		return conn, conn.Err()
+	case <-ctx.Done():
+		// return connection to pool when it's available
+		go func() {
+			(<-result).Close()
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+/*
+Ping pings redis server
+*/
+func (r *RedisConnection) Ping(ctx context.Context) (err error) {
+	var conn redis.Conn
+	if conn, err = r.GetContext(ctx); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("PING")
+	return
+}
+
+/*
+Close closes pool
+*/
+func (r *RedisConnection) Close() error {
+	return r.Pool.Close()
+}
+
+/*
+Cassandra connection
+*/
+
+/*
+CassandraConnectionConfig is config for cassandra connection
+*/
+type CassandraConnectionConfig struct {
+	Cluster  []string `json:"cluster"`
+	Keyspace string   `json:"keyspace"`
+}
+
+/*
+CassandraConnectionFactory prepares cassandra session, session is created on first use.
+*/
+func CassandraConnectionFactory(config *ConnectionConfig) (result Connection, err error) {
+	cc := &CassandraConnectionConfig{}
+	if err = decodeConfig(config.Config, cc); err != nil {
+		return
+	}
+
+	if len(cc.Cluster) == 0 {
+		return nil, errors.New("cluster must have at least one host")
+	}
+
+	cluster := gocql.NewCluster(cc.Cluster...)
+	cluster.Keyspace = cc.Keyspace
+	if config.PoolSize > 0 {
+		cluster.NumConns = config.PoolSize
+	}
+
+	result = &CassandraConnection{
+		cluster: cluster,
+	}
+	return
+}
+
+/*
+CassandraConnection is shared cassandra session
+*/
+type CassandraConnection struct {
+	lock    sync.Mutex
+	cluster *gocql.ClusterConfig
+	session *gocql.Session
+}
+
+/*
+Session returns shared session, it's created on first call
+*/
+func (c *CassandraConnection) Session() (session *gocql.Session, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.session != nil && !c.session.Closed() {
+		return c.session, nil
+	}
+
+	if c.session, err = c.cluster.CreateSession(); err != nil {
+		c.session = nil
+		return
+	}
+
+	return c.session, nil
+}
+
+/*
+Ping runs simple query on cluster
+*/
+func (c *CassandraConnection) Ping(ctx context.Context) (err error) {
+	var session *gocql.Session
+	if session, err = c.Session(); err != nil {
+		return
+	}
+
+	return session.Query("SELECT now() FROM system.local").WithContext(ctx).Exec()
+}
+
+/*
+Close closes session
+*/
+func (c *CassandraConnection) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.session != nil {
+		c.session.Close()
+		c.session = nil
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package goexpose
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConnections(t *testing.T) {
+
+	RegisterConnectionFactory("test", func(config *ConnectionConfig) (Connection, error) {
+		return &testConnection{}, nil
+	})
+
+	Convey("Test update", t, func() {
+		connections := newConnections()
+		defer connections.Close()
+
+		connections.Update(map[string]*ConnectionConfig{
+			"kept":    {Type: "test", PoolSize: 1},
+			"changed": {Type: "test", PoolSize: 1},
+			"removed": {Type: "test", PoolSize: 1},
+		})
+
+		get := func(name string) *testConnection {
+			connection, err := connections.Get(name)
+			So(err, ShouldBeNil)
+			return connection.(*testConnection)
+		}
+		kept, changed, removed := get("kept"), get("changed"), get("removed")
+
+		// configs are compared by value
+		connections.Update(map[string]*ConnectionConfig{
+			"kept":    {Type: "test", PoolSize: 1},
+			"changed": {Type: "test", PoolSize: 2},
+		})
+
+		So(kept.closed, ShouldBeFalse)
+		So(get("kept"), ShouldEqual, kept)
+
+		So(changed.closed, ShouldBeTrue)
+		So(get("changed"), ShouldNotEqual, changed)
+
+		So(removed.closed, ShouldBeTrue)
+		_, err := connections.Get("removed")
+		So(err, ShouldEqual, ErrConnectionNotFound)
+	})
+
+	Convey("Test close", t, func() {
+		connections := newConnections()
+		connections.Update(map[string]*ConnectionConfig{
+			"test": {Type: "test"},
+		})
+
+		connection, err := connections.Get("test")
+		So(err, ShouldBeNil)
+
+		connections.Close()
+		So(connection.(*testConnection).closed, ShouldBeTrue)
+		So(connections.Status(), ShouldBeEmpty)
+	})
+}
+
+/*
+testConnection remembers whether it was closed
+*/
+type testConnection struct {
+	closed bool
+}
+
+func (t *testConnection) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (t *testConnection) Close() error {
+	t.closed = true
+	return nil
+}
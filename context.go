@@ -0,0 +1,201 @@
+package goexpose
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+/*
+NewContextTasker returns ContextTasker for given task. Tasks that don't support
+context are run in separate goroutine and when context is done, timeout response
+is returned (task itself cannot be stopped).
+*/
+func NewContextTasker(task Tasker) ContextTasker {
+	if ct, ok := task.(ContextTasker); ok {
+		return ct
+	}
+	return &taskerAdapter{Tasker: task}
+}
+
+/*
+taskerAdapter adapts Tasker without context support to ContextTasker
+*/
+type taskerAdapter struct {
+	Tasker
+}
+
+/*
+RunContext runs task and waits for result or for context to be done
+*/
+func (t *taskerAdapter) RunContext(ctx context.Context, r *http.Request, vars map[string]interface{}) (response *Response) {
+	result := make(chan *Response, 1)
+
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				result <- NewResponse(http.StatusInternalServerError).Error(e)
+			}
+		}()
+		result <- t.Tasker.Run(r, vars)
+	}()
+
+	select {
+	case response = <-result:
+		return
+	case <-ctx.Done():
+		return NewResponse(contextStatus(ctx)).Error(ctx.Err().Error())
+	}
+}
+
+/*
+taskContext returns context with timeout, if timeout is zero context is returned
+without timeout.
+*/
+func taskContext(ctx context.Context, timeout Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(timeout))
+}
+
+/*
+contextStatus returns http status for context that is done
+*/
+func contextStatus(ctx context.Context) int {
+	if ctx.Err() == context.DeadlineExceeded {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusServiceUnavailable
+}
+
+/*
+requestState holds information about request being handled, it's stored in
+request context, so it's available to authorizers and tasks.
+*/
+type requestState struct {
+	// route path (with mux variables), route name and method
+	Route     string
+	RouteName string
+	Method    string
+
+	// type of task that handles request
+	TaskType string
+
+	// authenticated user set by authorizer
+	User string
+
+	// verified claims set by authorizer (e.g. jwt)
+	Claims map[string]interface{}
+
+	// request id and trace context of request span
+	RequestID string
+	Trace     traceContext
+}
+
+type requestStateKey struct{}
+
+/*
+withRequestState returns context with given request state
+*/
+func withRequestState(ctx context.Context, state *requestState) context.Context {
+	return context.WithValue(ctx, requestStateKey{}, state)
+}
+
+/*
+getRequestState returns request state from context, if context does not have
+request state, empty one is returned.
+*/
+func getRequestState(ctx context.Context) *requestState {
+	if state, ok := ctx.Value(requestStateKey{}).(*requestState); ok {
+		return state
+	}
+	return &requestState{}
+}
+
+/*
+SetRequestUser sets authenticated user of request, authorizers call it so user
+is logged in access log.
+*/
+func SetRequestUser(r *http.Request, user string) {
+	getRequestState(r.Context()).User = user
+}
+
+/*
+newTaskItems returns collector for sub items of given task type
+*/
+func newTaskItems(taskType string) *taskItems {
+	return &taskItems{
+		taskType: taskType,
+		last:     time.Now(),
+	}
+}
+
+/*
+taskItems checks results of task sub items (commands, queries, urls). It counts
+failed items, records spans of items and collects names of items that timed out.
+*/
+type taskItems struct {
+	taskType string
+	timedOut []string
+
+	// items are run sequentially, so item started when previous one finished
+	last time.Time
+}
+
+/*
+Check checks result of sub item. Failed items are counted in metrics, items that
+failed because of context deadline are marked.
+*/
+func (t *taskItems) Check(ctx context.Context, item *Response, format string, args ...interface{}) {
+	name := fmt.Sprintf(format, args...)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		t.checkTimeout(ctx, item, name)
+	}
+
+	_, span := startSpan(ctx, t.taskType+" "+name, spanKindInternal)
+	span.start = t.last
+	span.SetAttribute("goexpose.task.type", t.taskType).SetAttribute("goexpose.task.item", name)
+
+	if item.HasValue("error") {
+		metrics.taskItemErrors.Inc(t.taskType, getRequestState(ctx).Route, name)
+		span.SetError(item.data["error"])
+	}
+
+	span.End()
+	t.last = span.end
+}
+
+/*
+checkTimeout marks item that timed out
+*/
+func (t *taskItems) checkTimeout(ctx context.Context, item *Response, name string) {
+	// timed out sub task
+	if item.GetStatus() == http.StatusGatewayTimeout {
+		if nested, ok := item.data["timed_out"].([]string); ok {
+			for _, n := range nested {
+				t.timedOut = append(t.timedOut, name+"."+n)
+			}
+			return
+		}
+	}
+
+	if !item.HasValue("error") {
+		return
+	}
+
+	item.Error(ctx.Err().Error()).AddValue("timeout", true)
+	t.timedOut = append(t.timedOut, name)
+}
+
+/*
+Apply sets gateway timeout status to response if any of items timed out.
+*/
+func (t *taskItems) Apply(response *Response) *Response {
+	if len(t.timedOut) == 0 {
+		return response
+	}
+	return response.Status(http.StatusGatewayTimeout).AddValue("timed_out", t.timedOut)
+}
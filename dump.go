@@ -0,0 +1,58 @@
+package goexpose
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+/*
+Dump returns effective configuration as json: includes, endpoint groups and
+task templates are already merged and defaults are set (when configuration was
+validated). Encrypted values stay encrypted and values with variables are printed
+with variables, so secrets read from files and environment are not revealed.
+*/
+func (c *Config) Dump() (result []byte, err error) {
+	var body []byte
+	if body, err = json.Marshal(c); err != nil {
+		return
+	}
+
+	var value map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err = decoder.Decode(&value); err != nil {
+		return
+	}
+
+	// included files and groups are merged into endpoints
+	delete(value, "include")
+	delete(value, "groups")
+
+	mapStrings(value, func(s string) string {
+		if encrypted, ok := c.secrets[s]; ok {
+			return encrypted
+		}
+		return s
+	})
+
+	return json.MarshalIndent(value, "", "    ")
+}
+
+/*
+mapStrings replaces all strings in json value (map keys are not changed)
+*/
+func mapStrings(value interface{}, fn func(string) string) interface{} {
+	switch value := value.(type) {
+	case string:
+		return fn(value)
+	case []interface{}:
+		for i, item := range value {
+			value[i] = mapStrings(item, fn)
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = mapStrings(item, fn)
+		}
+	}
+	return value
+}
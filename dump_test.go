@@ -0,0 +1,41 @@
+package goexpose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDump(t *testing.T) {
+
+	Convey("Test variables are not revealed", t, func() {
+		directory, err := ioutil.TempDir("", "goexpose")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(directory)
+
+		So(ioutil.WriteFile(filepath.Join(directory, "password"), []byte("s3cret\n"), 0600), ShouldBeNil)
+
+		filename := filepath.Join(directory, "config.json")
+		So(ioutil.WriteFile(filename, []byte(`{
+			"authorizers": {
+				"basic": {"type": "basic", "config": {"username": "user", "password": "${file:password}"}}
+			},
+			"endpoints": [
+				{"path": "/shell", "methods": {"GET": {"type": "shell", "config": {"commands": [{"command": "echo ${HOME}"}]}}}}
+			]
+		}`), 0600), ShouldBeNil)
+
+		config, err := NewConfigFromFilename(filename, "")
+		So(err, ShouldBeNil)
+		So(string(config.Authorizers["basic"].Config), ShouldContainSubstring, "s3cret")
+
+		body, err := config.Dump()
+		So(err, ShouldBeNil)
+		So(string(body), ShouldNotContainSubstring, "s3cret")
+		So(string(body), ShouldContainSubstring, `"password": "${file:password}"`)
+		So(string(body), ShouldContainSubstring, `"command": "echo ${HOME}"`)
+	})
+}
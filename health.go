@@ -0,0 +1,442 @@
+package goexpose
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Health module
+
+Liveness endpoint reports that process is running, readiness endpoint runs
+probes of dependencies that endpoints use (databases, redis, cassandra, files,
+ldap servers and named connections).
+*/
+
+var (
+	// kinds of checks that can be enabled
+	healthCheckKinds = map[string]bool{
+		"connection": true,
+		"postgres":   true,
+		"mysql":      true,
+		"redis":      true,
+		"cassandra":  true,
+		"filesystem": true,
+		"ldap":       true,
+	}
+
+	// password in key=value dsn
+	dsnPasswordRegex = regexp.MustCompile(`password=\S+`)
+)
+
+/*
+HealthConfig is configuration of health endpoints
+*/
+type HealthConfig struct {
+	// paths of endpoints, defaults are /healthz and /readyz
+	LivenessPath  string `json:"liveness_path"`
+	ReadinessPath string `json:"readiness_path"`
+
+	// default timeout of single check
+	Timeout Duration `json:"timeout"`
+
+	// enabled checks by kind, if empty all checks are enabled
+	Checks map[string]*HealthCheckConfig `json:"checks"`
+
+	// authorizers for health endpoints
+	Authorizers []string `json:"authorizers"`
+}
+
+/*
+HealthCheckConfig is configuration of checks of given kind
+*/
+type HealthCheckConfig struct {
+	// timeout of check, default is health timeout
+	Timeout Duration `json:"timeout"`
+}
+
+/*
+Validate validates health configuration
+*/
+func (h *HealthConfig) Validate() (err error) {
+	if h.LivenessPath = strings.TrimSpace(h.LivenessPath); h.LivenessPath == "" {
+		h.LivenessPath = DEFAULT_LIVENESS_PATH
+	}
+	if h.ReadinessPath = strings.TrimSpace(h.ReadinessPath); h.ReadinessPath == "" {
+		h.ReadinessPath = DEFAULT_READINESS_PATH
+	}
+	for _, path := range []string{h.LivenessPath, h.ReadinessPath} {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("health: path %s must start with /", path)
+		}
+	}
+	if h.LivenessPath == h.ReadinessPath {
+		return fmt.Errorf("health: liveness and readiness paths must differ")
+	}
+
+	if h.Timeout < 0 {
+		return fmt.Errorf("health: invalid timeout")
+	}
+	if h.Timeout == 0 {
+		h.Timeout = Duration(DEFAULT_HEALTH_TIMEOUT)
+	}
+
+	for kind, check := range h.Checks {
+		if !healthCheckKinds[kind] {
+			return fmt.Errorf("health: unknown check %s", kind)
+		}
+		if check != nil && check.Timeout < 0 {
+			return fmt.Errorf("health: check %s has invalid timeout", kind)
+		}
+	}
+	return
+}
+
+/*
+CheckTimeout returns timeout for checks of given kind, ok is false when checks
+of given kind are disabled.
+*/
+func (h *HealthConfig) CheckTimeout(kind string) (timeout time.Duration, ok bool) {
+	timeout = time.Duration(h.Timeout)
+	if len(h.Checks) == 0 {
+		return timeout, true
+	}
+
+	var check *HealthCheckConfig
+	if check, ok = h.Checks[kind]; !ok {
+		return
+	}
+	if check != nil && check.Timeout > 0 {
+		timeout = time.Duration(check.Timeout)
+	}
+	return
+}
+
+/*
+validateBuiltinPaths checks that paths of built-in endpoints (metrics, health)
+are unique and not used by endpoints.
+*/
+func (c *Config) validateBuiltinPaths() (err error) {
+	builtin := map[string]string{}
+	add := func(name, path string) error {
+		if other, ok := builtin[path]; ok {
+			return fmt.Errorf("%s: path %s is already used by %s", name, path, other)
+		}
+		builtin[path] = name
+		return nil
+	}
+
+	if c.Metrics != nil {
+		if err = add("metrics", c.Metrics.Path); err != nil {
+			return
+		}
+	}
+	if c.Health != nil {
+		if err = add("health", c.Health.LivenessPath); err != nil {
+			return
+		}
+		if err = add("health", c.Health.ReadinessPath); err != nil {
+			return
+		}
+	}
+	if c.OpenAPI != nil {
+		if err = add("openapi", c.OpenAPI.Path); err != nil {
+			return
+		}
+	}
+
+	for _, econfig := range c.Endpoints {
+		if name, ok := builtin[econfig.Path]; ok {
+			return fmt.Errorf("%s: path %s is already used by endpoint", name, econfig.Path)
+		}
+	}
+	return
+}
+
+/*
+probes returns probes of all routes, authorizers and named connections. Same
+dependencies are probed only once.
+*/
+func (s *Server) probes(routes []*route, authorizers Authorizers) (result []Probe) {
+	seen := map[string]bool{}
+	add := func(probes ...Probe) {
+		for _, probe := range probes {
+			if key := probe.Kind + "\x00" + probe.Name; !seen[key] {
+				seen[key] = true
+				result = append(result, probe)
+			}
+		}
+	}
+
+	for name := range s.Config.Connections {
+		add(s.connectionProbe(name))
+	}
+
+	for _, route := range routes {
+		if prober, ok := route.Task.(Prober); ok {
+			add(prober.Probes()...)
+		}
+	}
+
+	for _, authorizer := range authorizers {
+		if prober, ok := authorizer.(Prober); ok {
+			add(prober.Probes()...)
+		}
+	}
+	return
+}
+
+/*
+connectionProbe returns probe of named connection
+*/
+func (s *Server) connectionProbe(name string) Probe {
+	return Probe{
+		Kind: "connection",
+		Name: name,
+		Check: func(ctx context.Context) (err error) {
+			var connection Connection
+			if connection, err = s.connections.Get(name); err != nil {
+				return
+			}
+			return connection.Ping(ctx)
+		},
+	}
+}
+
+/*
+HealthCheck is result of single probe
+*/
+type HealthCheck struct {
+	Kind     string  `json:"kind"`
+	Name     string  `json:"name"`
+	Healthy  bool    `json:"healthy"`
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+/*
+runProbes runs enabled probes concurrently, every probe has its own timeout
+*/
+func runProbes(ctx context.Context, hc *HealthConfig, probes []Probe) (result []HealthCheck) {
+	result = make([]HealthCheck, 0, len(probes))
+
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+
+	for _, probe := range probes {
+		timeout, ok := hc.CheckTimeout(probe.Kind)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(probe Probe, timeout time.Duration) {
+			defer wg.Done()
+
+			pctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := probeContext(pctx, probe.Check)
+
+			check := HealthCheck{
+				Kind:     probe.Kind,
+				Name:     probe.Name,
+				Healthy:  err == nil,
+				Duration: time.Since(start).Seconds(),
+			}
+			if err != nil {
+				check.Error = err.Error()
+			}
+
+			lock.Lock()
+			result = append(result, check)
+			lock.Unlock()
+		}(probe, timeout)
+	}
+
+	wg.Wait()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		return result[i].Name < result[j].Name
+	})
+	return
+}
+
+/*
+probeContext runs check and returns context error when it does not finish in time
+(not all clients support context).
+*/
+func probeContext(ctx context.Context, check func(ctx context.Context) error) error {
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				result <- fmt.Errorf("%v", e)
+			}
+		}()
+		result <- check(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+LivenessHandler returns handler that reports that server is running
+*/
+func (s *Server) LivenessHandler(authorizers Authorizers, hc *HealthConfig) http.HandlerFunc {
+	ec := &EndpointConfig{
+		Path:        hc.LivenessPath,
+		Authorizers: hc.Authorizers,
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+
+		state := getRequestState(r.Context())
+		state.Route = ec.Path
+		state.RouteName = ec.RouteName()
+
+		if err := authorizers.Authorize(r, ec); err != nil {
+			NewResponse(http.StatusUnauthorized).Write(w, r, t)
+			return
+		}
+
+		NewResponse(http.StatusOK).Result(map[string]interface{}{
+			"alive":   true,
+			"version": s.Version,
+		}).Write(w, r, t)
+	}
+}
+
+/*
+ReadinessHandler returns handler that runs probes and reports their results.
+Server is not ready when any probe fails or when server is shutting down.
+*/
+func (s *Server) ReadinessHandler(authorizers Authorizers, hc *HealthConfig, probes []Probe) http.HandlerFunc {
+	ec := &EndpointConfig{
+		Path:        hc.ReadinessPath,
+		Authorizers: hc.Authorizers,
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+
+		state := getRequestState(r.Context())
+		state.Route = ec.Path
+		state.RouteName = ec.RouteName()
+
+		if err := authorizers.Authorize(r, ec); err != nil {
+			NewResponse(http.StatusUnauthorized).Write(w, r, t)
+			return
+		}
+
+		select {
+		case <-s.stop:
+			NewResponse(http.StatusServiceUnavailable).Result(map[string]interface{}{
+				"ready": false,
+			}).Error("server is shutting down").Write(w, r, t)
+			return
+		default:
+		}
+
+		checks := runProbes(r.Context(), hc, probes)
+
+		ready := true
+		for _, check := range checks {
+			ready = ready && check.Healthy
+		}
+
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+
+		NewResponse(status).Result(map[string]interface{}{
+			"ready":  ready,
+			"checks": checks,
+		}).Write(w, r, t)
+	}
+}
+
+/*
+isTemplate returns whether value is interpolated on request, such values
+cannot be probed.
+*/
+func isTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+/*
+redactDSN removes password from dsn so it can be shown in health checks
+*/
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.User != nil {
+		return u.Redacted()
+	}
+
+	// user:password@tcp(host:port)/db
+	if at := strings.LastIndex(dsn, "@"); at != -1 {
+		if colon := strings.Index(dsn[:at], ":"); colon != -1 {
+			return dsn[:colon+1] + "xxxxx" + dsn[at:]
+		}
+	}
+
+	// host=localhost password=secret
+	return dsnPasswordRegex.ReplaceAllString(dsn, "password=xxxxx")
+}
+
+/*
+sqlProbe returns probe that connects to database
+*/
+func sqlProbe(driver, dsn string) Probe {
+	return Probe{
+		Kind: driver,
+		Name: redactDSN(dsn),
+		Check: func(ctx context.Context) error {
+			db, err := connectContext(ctx, driver, dsn)
+			if err == nil {
+				db.Close()
+			}
+			return err
+		},
+	}
+}
+
+/*
+fileProbe returns probe that checks existence of file or directory
+*/
+func fileProbe(filename string, directory bool) Probe {
+	return Probe{
+		Kind: "filesystem",
+		Name: filename,
+		Check: func(ctx context.Context) (err error) {
+			var info os.FileInfo
+			if info, err = os.Stat(filename); err != nil {
+				return
+			}
+			if directory && !info.IsDir() {
+				return fmt.Errorf("%s is not directory", filename)
+			}
+			return
+		},
+	}
+}
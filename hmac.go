@@ -0,0 +1,404 @@
+package goexpose
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+hmac authorizer
+
+hmac authorizer verifies signature of request computed by shared secret. Signed
+string is built from configured components (method, path, query, headers,
+timestamp, nonce and body) joined by separator. Requests with timestamp older
+than max age are rejected and nonces (signature when nonce header is not
+configured) are remembered, so request cannot be replayed. Body is restored
+after verification, so tasks can read it.
+*/
+
+var (
+	ErrHMACSignatureMissing = errors.New("hmac: signature missing")
+	ErrHMACSignatureInvalid = errors.New("hmac: invalid signature")
+	ErrHMACTimestampInvalid = errors.New("hmac: invalid timestamp")
+	ErrHMACTimestampStale   = errors.New("hmac: stale timestamp")
+	ErrHMACNonceMissing     = errors.New("hmac: nonce missing")
+	ErrHMACReplay           = errors.New("hmac: request replayed")
+	ErrHMACNonceCacheFull   = errors.New("hmac: nonce cache full")
+	ErrHMACBodyTooLarge     = errors.New("hmac: body too large")
+)
+
+var (
+	// supported hash functions
+	hmacHashes = map[string]func() hash.Hash{
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+	}
+
+	// supported components of signed string
+	hmacComponents = map[string]bool{
+		"method":    true,
+		"path":      true,
+		"query":     true,
+		"headers":   true,
+		"timestamp": true,
+		"nonce":     true,
+		"body":      true,
+	}
+
+	// default components of signed string
+	hmacDefaultComponents = []string{"method", "path", "headers", "timestamp", "nonce", "body"}
+)
+
+/*
+HMACAuthorizerConfig is configuration of hmac authorizer
+*/
+type HMACAuthorizerConfig struct {
+	// shared secret (can be encrypted value) or file with secret
+	Secret     string `json:"secret"`
+	SecretFile string `json:"secret_file"`
+
+	// hash function (sha1, sha256, sha512), default is sha256
+	Hash string `json:"hash"`
+
+	// header with signature, its encoding (hex, base64) and prefix stripped from value (e.g. "sha256=")
+	SignatureHeader   string `json:"signature_header"`
+	SignatureEncoding string `json:"signature_encoding"`
+	SignaturePrefix   string `json:"signature_prefix"`
+
+	// header with unix timestamp of request and maximum age of request
+	TimestampHeader string   `json:"timestamp_header"`
+	MaxAge          Duration `json:"max_age"`
+
+	// header with unique nonce, when empty signature is used as nonce
+	NonceHeader string `json:"nonce_header"`
+
+	// maximum number of remembered nonces
+	NonceCacheSize int `json:"nonce_cache_size"`
+
+	// components of signed string in order and their separator (default newline)
+	Components []string `json:"components"`
+	Separator  *string  `json:"separator"`
+
+	// headers included in signed string in given order as "name:value"
+	Headers []string `json:"headers"`
+
+	// maximum size of body in bytes
+	MaxBodySize int64 `json:"max_body_size"`
+}
+
+/*
+Validate validates hmac authorizer config and sets defaults
+*/
+func (h *HMACAuthorizerConfig) Validate() (err error) {
+	h.Secret = strings.TrimSpace(h.Secret)
+	h.SecretFile = strings.TrimSpace(h.SecretFile)
+
+	if h.Secret == "" && h.SecretFile == "" {
+		return errors.New("hmac: please provide secret or secret_file")
+	}
+	if h.Secret != "" && h.SecretFile != "" {
+		return errors.New("hmac: secret and secret_file set, that doesn't make sense")
+	}
+
+	if h.Hash = strings.ToLower(strings.TrimSpace(h.Hash)); h.Hash == "" {
+		h.Hash = DEFAULT_HMAC_HASH
+	}
+	if _, ok := hmacHashes[h.Hash]; !ok {
+		return fmt.Errorf("hmac: unsupported hash %s", h.Hash)
+	}
+
+	if h.SignatureHeader = strings.TrimSpace(h.SignatureHeader); h.SignatureHeader == "" {
+		h.SignatureHeader = DEFAULT_HMAC_SIGNATURE_HEADER
+	}
+	if h.SignatureEncoding = strings.ToLower(strings.TrimSpace(h.SignatureEncoding)); h.SignatureEncoding == "" {
+		h.SignatureEncoding = "hex"
+	}
+	if h.SignatureEncoding != "hex" && h.SignatureEncoding != "base64" {
+		return fmt.Errorf("hmac: unsupported signature_encoding %s", h.SignatureEncoding)
+	}
+
+	if h.TimestampHeader = strings.TrimSpace(h.TimestampHeader); h.TimestampHeader == "" {
+		h.TimestampHeader = DEFAULT_HMAC_TIMESTAMP_HEADER
+	}
+	h.NonceHeader = strings.TrimSpace(h.NonceHeader)
+
+	if h.MaxAge < 0 || h.NonceCacheSize < 0 || h.MaxBodySize < 0 {
+		return errors.New("hmac: max_age, nonce_cache_size and max_body_size must not be negative")
+	}
+	if h.MaxAge == 0 {
+		h.MaxAge = Duration(DEFAULT_HMAC_MAX_AGE)
+	}
+	if h.NonceCacheSize == 0 {
+		h.NonceCacheSize = DEFAULT_HMAC_NONCE_CACHE_SIZE
+	}
+	if h.MaxBodySize == 0 {
+		h.MaxBodySize = DEFAULT_HMAC_MAX_BODY_SIZE
+	}
+
+	if len(h.Components) == 0 {
+		h.Components = append([]string{}, hmacDefaultComponents...)
+	}
+	timestamp, nonce := false, false
+	for i, component := range h.Components {
+		h.Components[i] = strings.ToLower(strings.TrimSpace(component))
+		if !hmacComponents[h.Components[i]] {
+			return fmt.Errorf("hmac: unknown component %s", component)
+		}
+		timestamp = timestamp || h.Components[i] == "timestamp"
+		nonce = nonce || h.Components[i] == "nonce"
+	}
+	// unsigned timestamp could be changed, so stale requests could be replayed
+	if !timestamp {
+		return errors.New("hmac: components must contain timestamp")
+	}
+	// unsigned nonce could be changed, so requests could be replayed within max_age
+	if h.NonceHeader != "" && !nonce {
+		return errors.New("hmac: components must contain nonce when nonce_header is set")
+	}
+
+	if h.Separator == nil {
+		separator := "\n"
+		h.Separator = &separator
+	}
+
+	for i, header := range h.Headers {
+		h.Headers[i] = strings.TrimSpace(header)
+	}
+	return
+}
+
+/*
+newHMACAuthorizerConfig returns validated config of authorizer config
+*/
+func newHMACAuthorizerConfig(ac *AuthorizerConfig) (config *HMACAuthorizerConfig, err error) {
+	config = &HMACAuthorizerConfig{}
+	if err = decodeConfig(ac.Config, config); err != nil {
+		return
+	}
+	err = config.Validate()
+	return
+}
+
+func HMACAuthorizerFactory(ac *AuthorizerConfig) (result Authorizer, err error) {
+	var config *HMACAuthorizerConfig
+	if config, err = newHMACAuthorizerConfig(ac); err != nil {
+		return
+	}
+
+	authorizer := &HMACAuthorizer{
+		config: config,
+		secret: []byte(config.Secret),
+		nonces: newNonceCache(config.NonceCacheSize),
+	}
+
+	if config.SecretFile != "" {
+		var body []byte
+		if body, err = ioutil.ReadFile(config.SecretFile); err != nil {
+			return nil, fmt.Errorf("hmac: %v", err)
+		}
+		authorizer.secret = bytes.TrimSpace(body)
+	}
+	if len(authorizer.secret) == 0 {
+		return nil, errors.New("hmac: secret is empty")
+	}
+
+	result = authorizer
+	return
+}
+
+/*
+HMACSecurityScheme describes hmac authorizer by its signature header
+*/
+func HMACSecurityScheme(ac *AuthorizerConfig) Schema {
+	config, err := newHMACAuthorizerConfig(ac)
+	if err != nil {
+		return nil
+	}
+	return Schema{"type": "apiKey", "in": "header", "name": config.SignatureHeader}
+}
+
+/*
+HMACAuthorizer verifies signed requests
+*/
+type HMACAuthorizer struct {
+	config *HMACAuthorizerConfig
+	secret []byte
+	nonces *nonceCache
+}
+
+/*
+Authorize verifies timestamp and signature of request and checks that request
+was not replayed
+*/
+func (h *HMACAuthorizer) Authorize(r *http.Request) (err error) {
+	return h.verify(r, time.Now())
+}
+
+/*
+verify verifies request at given time
+*/
+func (h *HMACAuthorizer) verify(r *http.Request, now time.Time) (err error) {
+	value := strings.TrimSpace(r.Header.Get(h.config.SignatureHeader))
+	if value == "" || !strings.HasPrefix(value, h.config.SignaturePrefix) {
+		return ErrHMACSignatureMissing
+	}
+
+	var signature []byte
+	if signature, err = h.decodeSignature(strings.TrimPrefix(value, h.config.SignaturePrefix)); err != nil {
+		return ErrHMACSignatureInvalid
+	}
+
+	// check timestamp before body is read
+	timestamp := strings.TrimSpace(r.Header.Get(h.config.TimestampHeader))
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrHMACTimestampInvalid
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > time.Duration(h.config.MaxAge) || age < -time.Duration(h.config.MaxAge) {
+		return ErrHMACTimestampStale
+	}
+
+	// without nonce header decoded signature is remembered, so re-encoded signature is still a replay
+	nonce := hex.EncodeToString(signature)
+	if h.config.NonceHeader != "" {
+		if nonce = strings.TrimSpace(r.Header.Get(h.config.NonceHeader)); nonce == "" {
+			return ErrHMACNonceMissing
+		}
+	}
+
+	var body []byte
+	if body, err = h.readBody(r); err != nil {
+		return
+	}
+
+	mac := hmac.New(hmacHashes[h.config.Hash], h.secret)
+	mac.Write([]byte(h.Canonical(r, timestamp, nonce, body)))
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return ErrHMACSignatureInvalid
+	}
+
+	// nonce is remembered only for valid requests, until timestamp cannot pass check
+	return h.nonces.Add(nonce, time.Unix(seconds, 0).Add(time.Duration(h.config.MaxAge)), now)
+}
+
+/*
+Canonical returns signed string of request
+*/
+func (h *HMACAuthorizer) Canonical(r *http.Request, timestamp, nonce string, body []byte) string {
+	parts := make([]string, 0, len(h.config.Components))
+	for _, component := range h.config.Components {
+		switch component {
+		case "method":
+			parts = append(parts, strings.ToUpper(r.Method))
+		case "path":
+			parts = append(parts, r.URL.EscapedPath())
+		case "query":
+			parts = append(parts, r.URL.RawQuery)
+		case "headers":
+			for _, header := range h.config.Headers {
+				parts = append(parts, strings.ToLower(header)+":"+strings.TrimSpace(r.Header.Get(header)))
+			}
+		case "timestamp":
+			parts = append(parts, timestamp)
+		case "nonce":
+			if h.config.NonceHeader != "" {
+				parts = append(parts, nonce)
+			}
+		case "body":
+			parts = append(parts, string(body))
+		}
+	}
+	return strings.Join(parts, *h.config.Separator)
+}
+
+/*
+decodeSignature decodes signature by configured encoding
+*/
+func (h *HMACAuthorizer) decodeSignature(value string) ([]byte, error) {
+	if h.config.SignatureEncoding == "base64" {
+		return base64.StdEncoding.Strict().DecodeString(value)
+	}
+	return hex.DecodeString(value)
+}
+
+/*
+readBody reads body of request and restores it, so it can be read again by task
+*/
+func (h *HMACAuthorizer) readBody(r *http.Request) (body []byte, err error) {
+	if r.Body == nil {
+		return
+	}
+
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, h.config.MaxBodySize+1)); err != nil {
+		return
+	}
+	if int64(len(body)) > h.config.MaxBodySize {
+		return nil, ErrHMACBodyTooLarge
+	}
+
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return
+}
+
+/*
+newNonceCache returns cache that remembers at most size nonces
+*/
+func newNonceCache(size int) *nonceCache {
+	return &nonceCache{
+		size:    size,
+		entries: map[string]time.Time{},
+	}
+}
+
+/*
+nonceCache remembers nonces until their expiration
+*/
+type nonceCache struct {
+	lock    sync.Mutex
+	size    int
+	entries map[string]time.Time
+}
+
+/*
+Add adds nonce that expires at given time. It fails when nonce was already seen
+or when cache is full of nonces that did not expire yet.
+*/
+func (n *nonceCache) Add(nonce string, expires time.Time, now time.Time) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if expiration, ok := n.entries[nonce]; ok && now.Before(expiration) {
+		return ErrHMACReplay
+	}
+
+	if len(n.entries) >= n.size {
+		for key, expiration := range n.entries {
+			if !now.Before(expiration) {
+				delete(n.entries, key)
+			}
+		}
+	}
+	if len(n.entries) >= n.size {
+		return ErrHMACNonceCacheFull
+	}
+
+	n.entries[nonce] = expires
+	return nil
+}
@@ -0,0 +1,116 @@
+package goexpose
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHMACAuthorizer(t *testing.T) {
+
+	newAuthorizer := func(config string) (*HMACAuthorizer, error) {
+		authorizer, err := HMACAuthorizerFactory(&AuthorizerConfig{Type: "hmac", Config: json.RawMessage(config)})
+		if err != nil {
+			return nil, err
+		}
+		return authorizer.(*HMACAuthorizer), nil
+	}
+
+	newRequest := func(timestamp time.Time, nonce, body, signed string) *http.Request {
+		request, _ := http.NewRequest("POST", "/hook?x=1", strings.NewReader(body))
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		request.Header.Set("X-Timestamp", ts)
+		request.Header.Set("X-Nonce", nonce)
+		request.Header.Set("X-Event", "push")
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(strings.Join([]string{"POST", "/hook", "x-event:push", ts, nonce, signed}, "\n")))
+		request.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		return request
+	}
+
+	Convey("Test signature, timestamp and replay", t, func() {
+		authorizer, err := newAuthorizer(`{"secret": "secret", "signature_prefix": "sha256=", "nonce_header": "X-Nonce", "headers": ["X-Event"], "max_age": "1m"}`)
+		So(err, ShouldBeNil)
+
+		now := time.Now()
+		request := newRequest(now, "n1", `{"ref": "main"}`, `{"ref": "main"}`)
+		So(authorizer.verify(request, now), ShouldBeNil)
+
+		// body is readable after verification
+		body, _ := ioutil.ReadAll(request.Body)
+		So(string(body), ShouldEqual, `{"ref": "main"}`)
+
+		So(authorizer.verify(newRequest(now, "n1", `{"ref": "main"}`, `{"ref": "main"}`), now), ShouldEqual, ErrHMACReplay)
+		So(authorizer.verify(newRequest(now, "n2", `{"ref": "other"}`, `{"ref": "main"}`), now), ShouldEqual, ErrHMACSignatureInvalid)
+		So(authorizer.verify(newRequest(now.Add(-2*time.Minute), "n3", "", ""), now), ShouldEqual, ErrHMACTimestampStale)
+		So(authorizer.verify(newRequest(now, "", "", ""), now), ShouldEqual, ErrHMACNonceMissing)
+
+		request = newRequest(now, "n4", "", "")
+		request.Header.Del("X-Signature")
+		So(authorizer.verify(request, now), ShouldEqual, ErrHMACSignatureMissing)
+
+		// nonce is signed, so replay with changed nonce fails
+		request = newRequest(now, "n5", "", "")
+		So(authorizer.verify(request, now), ShouldBeNil)
+		request.Header.Set("X-Nonce", "n6")
+		So(authorizer.verify(request, now), ShouldEqual, ErrHMACSignatureInvalid)
+
+		// nonce expires together with timestamp
+		So(authorizer.nonces.Add("n1", now, now.Add(2*time.Minute)), ShouldBeNil)
+	})
+
+	Convey("Test replay with re-encoded signature without nonce header", t, func() {
+		authorizer, err := newAuthorizer(`{"secret": "secret"}`)
+		So(err, ShouldBeNil)
+
+		now := time.Now()
+		ts := strconv.FormatInt(now.Unix(), 10)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(strings.Join([]string{"POST", "/hook", ts, "body"}, "\n")))
+		signature := hex.EncodeToString(mac.Sum(nil))
+
+		newSigned := func(signature string) *http.Request {
+			request, _ := http.NewRequest("POST", "/hook", strings.NewReader("body"))
+			request.Header.Set("X-Timestamp", ts)
+			request.Header.Set("X-Signature", signature)
+			return request
+		}
+
+		So(authorizer.verify(newSigned(signature), now), ShouldBeNil)
+		So(authorizer.verify(newSigned(signature), now), ShouldEqual, ErrHMACReplay)
+		So(authorizer.verify(newSigned(strings.ToUpper(signature)), now), ShouldEqual, ErrHMACReplay)
+		So(authorizer.verify(newSigned(" "+signature+" "), now), ShouldEqual, ErrHMACReplay)
+	})
+
+	Convey("Test nonce cache size", t, func() {
+		cache := newNonceCache(1)
+		now := time.Now()
+		So(cache.Add("a", now.Add(time.Minute), now), ShouldBeNil)
+		So(cache.Add("b", now.Add(time.Minute), now), ShouldEqual, ErrHMACNonceCacheFull)
+		So(cache.Add("b", now.Add(time.Minute), now.Add(time.Minute)), ShouldBeNil)
+	})
+
+	Convey("Test invalid config", t, func() {
+		_, err := newAuthorizer(`{}`)
+		So(err, ShouldNotBeNil)
+
+		_, err = newAuthorizer(`{"secret": "s", "hash": "md5"}`)
+		So(err, ShouldNotBeNil)
+
+		_, err = newAuthorizer(`{"secret": "s", "components": ["method", "body"]}`)
+		So(err, ShouldNotBeNil)
+
+		_, err = newAuthorizer(`{"secret": "s", "nonce_header": "X-Nonce", "components": ["method", "path", "timestamp", "body"]}`)
+		So(err, ShouldNotBeNil)
+	})
+}
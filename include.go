@@ -0,0 +1,358 @@
+package goexpose
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+Configuration can be split to multiple files. Main configuration can include
+other files by "include" (list of globs relative to configuration directory),
+or configuration can be loaded from directory (conf.d mode) where all files with
+known extension (.json, .yaml, .yml, .toml) are merged.
+
+Endpoints, endpoint groups, authorizers and task templates from all files are
+merged, every other setting can be defined only in one file.
+*/
+
+/*
+newConfigLoader returns loader that loads files into config
+*/
+func newConfigLoader(config *Config, format string) *configLoader {
+	return &configLoader{
+		config:      config,
+		format:      format,
+		loaded:      map[string]bool{},
+		settings:    map[string]string{},
+		endpoints:   map[string]string{},
+		authorizers: map[string]string{},
+		templates:   map[string]string{},
+	}
+}
+
+/*
+configLoader merges configuration files and remembers where settings came from
+*/
+type configLoader struct {
+	config *Config
+
+	// default format for files without known extension
+	format string
+
+	// loaded files and files/directories to watch for changes
+	loaded map[string]bool
+	files  []string
+
+	// key to decrypt encrypted values
+	secretsKey []byte
+
+	// sources of settings, endpoints ("METHOD path"), authorizers and task templates
+	settings    map[string]string
+	endpoints   map[string]string
+	authorizers map[string]string
+	templates   map[string]string
+}
+
+/*
+LoadFile loads configuration file and all files it includes
+*/
+func (l *configLoader) LoadFile(filename, format string) (err error) {
+	if l.loaded[filename] {
+		return
+	}
+	l.loaded[filename] = true
+	l.files = append(l.files, filename)
+
+	var body []byte
+	if body, err = ioutil.ReadFile(filename); err != nil {
+		return
+	}
+
+	configFormatsLock.RLock()
+	unmarshal, ok := configFormats[format]
+	configFormatsLock.RUnlock()
+	if !ok {
+		return errors.New("file format not found")
+	}
+
+	var document json.RawMessage
+	if err = unmarshal(body, &document); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+
+	// remember encrypted values and values with variables, so they are not
+	// revealed when configuration is dumped
+	if l.config.secrets == nil {
+		l.config.secrets = map[string]string{}
+	}
+	if l.secretsKey != nil {
+		findSecrets(document, l.secretsKey, l.config.secrets)
+	}
+	findVariables(document, l.config.Directory, l.config.secrets)
+
+	// resolve ${...} variables and encrypted values
+	if document, err = substituteVariables(document, l.config.Directory, l.secretsKey); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+
+	raw := map[string]json.RawMessage{}
+	if err = json.Unmarshal(document, &raw); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+
+	// apply settings in stable order
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var include []string
+	for _, key := range keys {
+		switch strings.ToLower(key) {
+		case "include":
+			if err = json.Unmarshal(raw[key], &include); err != nil {
+				return fmt.Errorf("%s: include: %v", filename, err)
+			}
+		case "$schema":
+			// reference to json schema for editors
+			continue
+		case "endpoints":
+			err = l.mergeEndpoints(filename, raw[key])
+		case "groups":
+			err = l.mergeGroups(filename, raw[key])
+		case "authorizers":
+			err = l.mergeAuthorizers(filename, raw[key])
+		case "task_templates":
+			err = l.mergeTaskTemplates(filename, raw[key])
+		default:
+			err = l.setSetting(filename, key, raw[key])
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	// main configuration remembers its includes
+	if filename == l.config.Filename {
+		l.config.Include = include
+	}
+
+	for _, pattern := range include {
+		if err = l.LoadGlob(pattern); err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+
+	return
+}
+
+/*
+LoadGlob loads all files matching pattern, relative patterns are relative to
+configuration directory.
+*/
+func (l *configLoader) LoadGlob(pattern string) (err error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(l.config.Directory, pattern)
+	}
+
+	var matches []string
+	if matches, err = filepath.Glob(pattern); err != nil {
+		return fmt.Errorf("include %s: %v", pattern, err)
+	}
+	sort.Strings(matches)
+
+	// watch directory so new files are noticed
+	l.files = append(l.files, filepath.Dir(pattern))
+
+	for _, match := range matches {
+		if info, e := os.Stat(match); e == nil && info.IsDir() {
+			continue
+		}
+		if err = l.LoadFile(match, formatByExtension(match, l.format)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+/*
+LoadDirectory loads all json and yaml files from directory (conf.d mode)
+*/
+func (l *configLoader) LoadDirectory(directory string) (err error) {
+	var infos []os.FileInfo
+	if infos, err = ioutil.ReadDir(directory); err != nil {
+		return
+	}
+
+	// watch directory so new files are noticed
+	l.files = append(l.files, directory)
+
+	found := false
+	for _, info := range infos {
+		if formatByExtension(info.Name(), "") == "" || info.IsDir() {
+			continue
+		}
+		found = true
+
+		filename := filepath.Join(directory, info.Name())
+		if err = l.LoadFile(filename, formatByExtension(filename, l.format)); err != nil {
+			return
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("no configuration files found in %s", directory)
+	}
+	return
+}
+
+/*
+setSetting sets top level setting, setting can be defined only in one file
+*/
+func (l *configLoader) setSetting(filename, key string, value json.RawMessage) (err error) {
+	name := strings.ToLower(key)
+	if other, ok := l.settings[name]; ok {
+		return fmt.Errorf("setting %s is defined in both %s and %s", key, other, filename)
+	}
+	l.settings[name] = filename
+
+	var body []byte
+	if body, err = json.Marshal(map[string]json.RawMessage{key: value}); err != nil {
+		return
+	}
+	if err = decodeConfig(body, l.config); err != nil {
+		return configSource{File: filename}.Wrap(err, "")
+	}
+	return
+}
+
+/*
+mergeEndpoints appends endpoints, same path and method cannot be defined in
+multiple files.
+*/
+func (l *configLoader) mergeEndpoints(filename string, value json.RawMessage) (err error) {
+	endpoints := []*EndpointConfig{}
+	if err = decodeConfig(value, &endpoints); err != nil {
+		return configSource{File: filename, Pointer: "/endpoints"}.Wrap(err, "")
+	}
+
+	for i, ec := range endpoints {
+		if ec != nil {
+			ec.source = configSource{File: filename, Pointer: fmt.Sprintf("/endpoints/%d", i)}
+		}
+	}
+
+	return l.addEndpoints(filename, endpoints)
+}
+
+/*
+mergeGroups appends endpoint groups, endpoints of groups are added to endpoints
+with group settings applied.
+*/
+func (l *configLoader) mergeGroups(filename string, value json.RawMessage) (err error) {
+	groups := []*EndpointGroupConfig{}
+	if err = decodeConfig(value, &groups); err != nil {
+		return configSource{File: filename, Pointer: "/groups"}.Wrap(err, "")
+	}
+
+	endpoints := []*EndpointConfig{}
+	for i, group := range groups {
+		if group == nil {
+			continue
+		}
+		for j, ec := range group.Endpoints {
+			if ec == nil {
+				continue
+			}
+			ec = group.Endpoint(ec)
+			ec.source = configSource{File: filename, Pointer: fmt.Sprintf("/groups/%d/endpoints/%d", i, j)}
+			endpoints = append(endpoints, ec)
+		}
+	}
+
+	if err = l.addEndpoints(filename, endpoints); err != nil {
+		return
+	}
+
+	l.config.Groups = append(l.config.Groups, groups...)
+	return
+}
+
+/*
+addEndpoints adds endpoints to configuration, same path and method cannot be
+defined in multiple files.
+*/
+func (l *configLoader) addEndpoints(filename string, endpoints []*EndpointConfig) (err error) {
+	for _, ec := range endpoints {
+		if ec == nil {
+			continue
+		}
+
+		for method := range ec.Methods {
+			key := method + " " + ec.Path
+			if other, ok := l.endpoints[key]; ok && other != filename {
+				return fmt.Errorf("endpoint %s %s is defined in both %s and %s", method, ec.Path, other, filename)
+			}
+			l.endpoints[key] = filename
+		}
+	}
+
+	l.config.Endpoints = append(l.config.Endpoints, endpoints...)
+	return
+}
+
+/*
+mergeAuthorizers adds authorizers, authorizer name can be defined only in one file.
+*/
+func (l *configLoader) mergeAuthorizers(filename string, value json.RawMessage) (err error) {
+	authorizers := map[string]*AuthorizerConfig{}
+	if err = decodeConfig(value, &authorizers); err != nil {
+		return configSource{File: filename, Pointer: "/authorizers"}.Wrap(err, "")
+	}
+
+	if l.config.Authorizers == nil {
+		l.config.Authorizers = map[string]*AuthorizerConfig{}
+	}
+
+	for name, ac := range authorizers {
+		if other, ok := l.authorizers[name]; ok {
+			return fmt.Errorf("authorizer %s is defined in both %s and %s", name, other, filename)
+		}
+		l.authorizers[name] = filename
+		if ac != nil {
+			ac.source = configSource{File: filename, Pointer: "/authorizers/" + escapePointer(name)}
+		}
+		l.config.Authorizers[name] = ac
+	}
+	return
+}
+
+/*
+mergeTaskTemplates adds task templates, template name can be defined only in one file.
+*/
+func (l *configLoader) mergeTaskTemplates(filename string, value json.RawMessage) (err error) {
+	templates := map[string]*TaskConfig{}
+	if err = decodeConfig(value, &templates); err != nil {
+		return configSource{File: filename, Pointer: "/task_templates"}.Wrap(err, "")
+	}
+
+	if l.config.TaskTemplates == nil {
+		l.config.TaskTemplates = map[string]*TaskConfig{}
+	}
+
+	for name, tc := range templates {
+		if other, ok := l.templates[name]; ok {
+			return fmt.Errorf("task template %s is defined in both %s and %s", name, other, filename)
+		}
+		l.templates[name] = filename
+		l.config.TaskTemplates[name] = tc
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package goexpose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInclude(t *testing.T) {
+
+	// writeFiles writes files to directory, paths returns paths of endpoints in order
+	writeFiles := func(directory string, files map[string]string) {
+		for name, body := range files {
+			filename := filepath.Join(directory, name)
+			So(os.MkdirAll(filepath.Dir(filename), 0700), ShouldBeNil)
+			So(ioutil.WriteFile(filename, []byte(body), 0600), ShouldBeNil)
+		}
+	}
+	paths := func(config *Config) (result []string) {
+		for _, ec := range config.Endpoints {
+			result = append(result, ec.Path)
+		}
+		return
+	}
+
+	Convey("Test include merge order", t, func() {
+		directory, err := ioutil.TempDir("", "goexpose")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(directory)
+
+		writeFiles(directory, map[string]string{
+			"config.json":   `{"include": ["conf.d/*"], "endpoints": [{"path": "/main", "methods": {"GET": {"type": "info"}}}]}`,
+			"conf.d/c.toml": "[[endpoints]]\npath = \"/c\"\nmethods.GET.type = \"info\"\n",
+			"conf.d/b.json": `{"include": ["nested/*.json"], "endpoints": [{"path": "/b", "methods": {"GET": {"type": "info"}}}]}`,
+			"conf.d/a.yaml": "endpoints:\n  - path: /a\n    methods:\n      GET:\n        type: info\n",
+			"nested/n.json": `{"port": 9981, "endpoints": [{"path": "/n", "methods": {"GET": {"type": "info"}}}]}`,
+		})
+
+		config, err := NewConfigFromFilename(filepath.Join(directory, "config.json"), "")
+		So(err, ShouldBeNil)
+
+		// main file first, included files in alphabetical order, nested includes after including file
+		So(paths(config), ShouldResemble, []string{"/main", "/a", "/b", "/n", "/c"})
+		So(config.Port, ShouldEqual, 9981)
+		So(config.Include, ShouldResemble, []string{"conf.d/*"})
+	})
+
+	Convey("Test conf.d merge order", t, func() {
+		directory, err := ioutil.TempDir("", "goexpose")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(directory)
+
+		writeFiles(directory, map[string]string{
+			"2.json":     `{"endpoints": [{"path": "/2", "methods": {"GET": {"type": "info"}}}]}`,
+			"10.yml":     "endpoints:\n  - path: /10\n    methods:\n      GET:\n        type: info\n",
+			"1.toml":     "[[endpoints]]\npath = \"/1\"\nmethods.GET.type = \"info\"\n",
+			"readme":     "not loaded",
+			"sub/3.json": `{"endpoints": [{"path": "/3", "methods": {"GET": {"type": "info"}}}]}`,
+		})
+
+		config, err := NewConfigFromFilename(directory, "")
+		So(err, ShouldBeNil)
+
+		// files are sorted by name, subdirectories are not loaded
+		So(paths(config), ShouldResemble, []string{"/1", "/10", "/2"})
+	})
+
+	Convey("Test settings defined in multiple files", t, func() {
+		directory, err := ioutil.TempDir("", "goexpose")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(directory)
+
+		writeFiles(directory, map[string]string{
+			"a.json": `{"port": 9981}`,
+			"b.json": `{"port": 9982}`,
+		})
+
+		_, err = NewConfigFromFilename(directory, "")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "a.json")
+		So(err.Error(), ShouldContainSubstring, "b.json")
+	})
+}
@@ -0,0 +1,88 @@
+package goexpose
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+inflightTask holds information about task that is currently running
+*/
+type inflightTask struct {
+	ID       uint64
+	Method   string
+	Path     string
+	Endpoint string
+	Type     string
+	Started  time.Time
+}
+
+/*
+newInflight returns new tracker of running tasks
+*/
+func newInflight() *inflight {
+	return &inflight{
+		tasks: map[uint64]*inflightTask{},
+	}
+}
+
+/*
+inflight tracks tasks that are currently running so we are able to report
+what has been interrupted during shutdown.
+*/
+type inflight struct {
+	lock  sync.Mutex
+	last  uint64
+	tasks map[uint64]*inflightTask
+}
+
+/*
+Add registers running task and returns its id
+*/
+func (i *inflight) Add(task *inflightTask) uint64 {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.last++
+	task.ID = i.last
+	task.Started = time.Now()
+	i.tasks[task.ID] = task
+	return task.ID
+}
+
+/*
+Remove removes finished task
+*/
+func (i *inflight) Remove(id uint64) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	delete(i.tasks, id)
+}
+
+/*
+Len returns count of running tasks
+*/
+func (i *inflight) Len() int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return len(i.tasks)
+}
+
+/*
+List returns copy of running tasks ordered by start time
+*/
+func (i *inflight) List() []inflightTask {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	result := make([]inflightTask, 0, len(i.tasks))
+	for _, task := range i.tasks {
+		result = append(result, *task)
+	}
+
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].ID < result[b].ID
+	})
+	return result
+}
@@ -1,6 +1,9 @@
 package goexpose
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 /*
 TaskFactory returns instance of task by server and config
@@ -19,6 +22,18 @@ type Tasker interface {
 	Run(r *http.Request, vars map[string]interface{}) *Response
 }
 
+/*
+ContextTasker is task that supports cancellation.
+Context passed to RunContext is cancelled when client disconnects, server shuts down
+or task timeout expires.
+*/
+type ContextTasker interface {
+	Tasker
+
+	// RunContext method is called on http request
+	RunContext(ctx context.Context, r *http.Request, vars map[string]interface{}) *Response
+}
+
 /*
 Base task
 */
@@ -30,3 +45,26 @@ Default path is blank
 func (t *Task) Path() string {
 	return ""
 }
+
+/*
+Prober is optionally implemented by tasks and authorizers that depend on
+external services (databases, files, ldap servers). Probes are run by readiness
+endpoint.
+*/
+type Prober interface {
+	Probes() []Probe
+}
+
+/*
+Probe checks single dependency
+*/
+type Probe struct {
+	// kind of dependency (postgres, mysql, redis, cassandra, filesystem, ldap, connection)
+	Kind string
+
+	// name of dependency (address, file), must not contain secrets
+	Name string
+
+	// Check returns error when dependency is not available
+	Check func(ctx context.Context) error
+}
@@ -0,0 +1,637 @@
+package goexpose
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+jwt authorizer
+
+jwt authorizer verifies bearer token from Authorization header. Tokens are
+signed by HS256 (shared secret), RS256 or ES256 (public keys from pem files or
+from local JWKS file). Verified claims are available to tasks as {{.claims}}.
+*/
+
+const (
+	JWT_HS256 = "HS256"
+	JWT_RS256 = "RS256"
+	JWT_ES256 = "ES256"
+
+	// maximum absolute value of time claim in seconds, larger floats lose precision
+	jwtMaxTimeClaim = 1 << 53
+)
+
+var (
+	ErrJWTMissing          = errors.New("jwt: bearer token missing")
+	ErrJWTMalformed        = errors.New("jwt: malformed token")
+	ErrJWTAlgorithm        = errors.New("jwt: algorithm not allowed")
+	ErrJWTSignature        = errors.New("jwt: invalid signature")
+	ErrJWTExpired          = errors.New("jwt: token expired")
+	ErrJWTNotValidYet      = errors.New("jwt: token not valid yet")
+	ErrJWTInvalidIssuer    = errors.New("jwt: invalid issuer")
+	ErrJWTInvalidAudience  = errors.New("jwt: invalid audience")
+	ErrJWTMissingClaim     = errors.New("jwt: required claim missing")
+	ErrJWTInvalidTimeClaim = errors.New("jwt: invalid time claim")
+)
+
+/*
+JWTAuthorizerConfig is configuration of jwt authorizer, at least one of secret,
+secret_file, key_files and jwks_file must be given.
+*/
+type JWTAuthorizerConfig struct {
+	// allowed algorithms (HS256, RS256, ES256), default are algorithms of configured keys
+	Algorithms []string `json:"algorithms"`
+
+	// shared secret for HS256 (can be encrypted value) or file with secret
+	Secret     string `json:"secret"`
+	SecretFile string `json:"secret_file"`
+
+	// pem files with RSA or EC public keys or certificates
+	KeyFiles []string `json:"key_files"`
+
+	// local JWKS file and how often it's checked for changes (default 1m)
+	JWKSFile           string   `json:"jwks_file"`
+	JWKSReloadInterval Duration `json:"jwks_reload_interval"`
+
+	// allowed issuers and audiences, token must match one of each if given
+	Issuers   []string `json:"issuers"`
+	Audiences []string `json:"audiences"`
+
+	// claims that must be present in token
+	RequiredClaims []string `json:"required_claims"`
+
+	// allowed clock skew for exp and nbf
+	Leeway Duration `json:"leeway"`
+
+	// claim with user name for access log, default is "sub"
+	UserClaim string `json:"user_claim"`
+}
+
+/*
+Validate validates jwt authorizer config
+*/
+func (j *JWTAuthorizerConfig) Validate() (err error) {
+	j.Secret = strings.TrimSpace(j.Secret)
+	j.SecretFile = strings.TrimSpace(j.SecretFile)
+	j.JWKSFile = strings.TrimSpace(j.JWKSFile)
+
+	if j.Secret == "" && j.SecretFile == "" && len(j.KeyFiles) == 0 && j.JWKSFile == "" {
+		return errors.New("jwt: please provide secret, secret_file, key_files or jwks_file")
+	}
+	if j.Secret != "" && j.SecretFile != "" {
+		return errors.New("jwt: secret and secret_file set, that doesn't make sense")
+	}
+
+	for i, algorithm := range j.Algorithms {
+		j.Algorithms[i] = strings.ToUpper(strings.TrimSpace(algorithm))
+		if _, ok := jwtAlgorithms[j.Algorithms[i]]; !ok {
+			return fmt.Errorf("jwt: unsupported algorithm %s", algorithm)
+		}
+	}
+
+	if j.JWKSReloadInterval < 0 || j.Leeway < 0 {
+		return errors.New("jwt: jwks_reload_interval and leeway must not be negative")
+	}
+	if j.JWKSReloadInterval == 0 {
+		j.JWKSReloadInterval = Duration(DEFAULT_JWKS_RELOAD_INTERVAL)
+	}
+
+	if j.UserClaim = strings.TrimSpace(j.UserClaim); j.UserClaim == "" {
+		j.UserClaim = "sub"
+	}
+	return
+}
+
+/*
+jwtKey is verification key, key is []byte (HS256), *rsa.PublicKey (RS256) or
+*ecdsa.PublicKey (ES256)
+*/
+type jwtKey struct {
+	id  string
+	key interface{}
+}
+
+/*
+jwtAlgorithms verifies signature of signing input by given key, it returns
+false when key is not key of algorithm.
+*/
+var jwtAlgorithms = map[string]func(key interface{}, input, signature []byte) bool{
+	JWT_HS256: func(key interface{}, input, signature []byte) bool {
+		secret, ok := key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return hmac.Equal(mac.Sum(nil), signature)
+	},
+	JWT_RS256: func(key interface{}, input, signature []byte) bool {
+		public, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		hash := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(public, crypto.SHA256, hash[:], signature) == nil
+	},
+	JWT_ES256: func(key interface{}, input, signature []byte) bool {
+		public, ok := key.(*ecdsa.PublicKey)
+		if !ok || public.Curve != elliptic.P256() || len(signature) != 64 {
+			return false
+		}
+		hash := sha256.Sum256(input)
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(public, hash[:], r, s)
+	},
+}
+
+func JWTAuthorizerFactory(ac *AuthorizerConfig) (result Authorizer, err error) {
+	config := &JWTAuthorizerConfig{}
+	if err = decodeConfig(ac.Config, config); err != nil {
+		return
+	}
+
+	if err = config.Validate(); err != nil {
+		return
+	}
+
+	authorizer := &JWTAuthorizer{
+		config: config,
+	}
+
+	// secret and pem keys are loaded once
+	if config.Secret != "" {
+		authorizer.keys = append(authorizer.keys, &jwtKey{key: []byte(config.Secret)})
+	}
+	if config.SecretFile != "" {
+		var body []byte
+		if body, err = ioutil.ReadFile(config.SecretFile); err != nil {
+			return nil, fmt.Errorf("jwt: %v", err)
+		}
+		authorizer.keys = append(authorizer.keys, &jwtKey{key: bytes.TrimSpace(body)})
+	}
+	for _, filename := range config.KeyFiles {
+		var key interface{}
+		if key, err = readJWTKeyFile(filename); err != nil {
+			return nil, fmt.Errorf("jwt: key file %s: %v", filename, err)
+		}
+		authorizer.keys = append(authorizer.keys, &jwtKey{key: key})
+	}
+
+	// jwks file is checked for changes when token is verified
+	if config.JWKSFile != "" {
+		if authorizer.jwks, err = readJWKSFile(config.JWKSFile); err != nil {
+			return nil, fmt.Errorf("jwt: jwks file %s: %v", config.JWKSFile, err)
+		}
+		authorizer.watcher = newFileWatcher(time.Duration(config.JWKSReloadInterval), nil, config.JWKSFile)
+		authorizer.checked = time.Now()
+	}
+
+	if len(config.Algorithms) == 0 {
+		config.Algorithms = authorizer.algorithms()
+	}
+
+	result = authorizer
+	return
+}
+
+/*
+JWTAuthorizer verifies bearer tokens
+*/
+type JWTAuthorizer struct {
+	config *JWTAuthorizerConfig
+
+	// keys from secret and pem files
+	keys []*jwtKey
+
+	// keys from jwks file, file is checked for changes at most once per reload interval
+	lock    sync.RWMutex
+	jwks    []*jwtKey
+	watcher *fileWatcher
+	checked time.Time
+}
+
+/*
+Authorize verifies bearer token and sets claims of request
+*/
+func (j *JWTAuthorizer) Authorize(r *http.Request) (err error) {
+	header := r.Header.Get("Authorization")
+	splitted := strings.SplitN(header, " ", 2)
+	if len(splitted) != 2 || !strings.EqualFold(splitted[0], "Bearer") {
+		return ErrJWTMissing
+	}
+
+	// reload is logged with request id
+	j.check(requestLogger(r.Context()))
+
+	var claims map[string]interface{}
+	if claims, err = j.Verify(strings.TrimSpace(splitted[1]), time.Now()); err != nil {
+		return
+	}
+
+	if user, ok := claims[j.config.UserClaim].(string); ok {
+		SetRequestUser(r, user)
+	}
+	SetRequestClaims(r, claims)
+	return
+}
+
+/*
+Verify verifies signature and claims of token at given time and returns claims
+*/
+func (j *JWTAuthorizer) Verify(token string, now time.Time) (claims map[string]interface{}, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTMalformed
+	}
+
+	header := struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}{}
+	if err = decodeJWTPart(parts[0], &header); err != nil {
+		return
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	// algorithm from token must be allowed, so public key cannot be used as hmac secret
+	verify, ok := jwtAlgorithms[header.Algorithm]
+	if !ok || !j.allowed(header.Algorithm) {
+		return nil, ErrJWTAlgorithm
+	}
+
+	input := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range j.candidates(header.KeyID) {
+		if verify(key.key, input, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrJWTSignature
+	}
+
+	if err = decodeJWTPart(parts[1], &claims); err != nil {
+		return
+	}
+	if err = j.verifyClaims(claims, now); err != nil {
+		return nil, err
+	}
+	return
+}
+
+/*
+verifyClaims checks time claims, issuer, audience and required claims
+*/
+func (j *JWTAuthorizer) verifyClaims(claims map[string]interface{}, now time.Time) (err error) {
+	leeway := time.Duration(j.config.Leeway)
+
+	var exp, nbf time.Time
+	if exp, err = jwtTimeClaim(claims, "exp"); err != nil {
+		return
+	}
+	if !exp.IsZero() && now.After(exp.Add(leeway)) {
+		return ErrJWTExpired
+	}
+	if nbf, err = jwtTimeClaim(claims, "nbf"); err != nil {
+		return
+	}
+	if !nbf.IsZero() && now.Add(leeway).Before(nbf) {
+		return ErrJWTNotValidYet
+	}
+	if _, err = jwtTimeClaim(claims, "iat"); err != nil {
+		return
+	}
+
+	if len(j.config.Issuers) > 0 {
+		issuer, _ := claims["iss"].(string)
+		if !stringInSlice(issuer, j.config.Issuers) {
+			return ErrJWTInvalidIssuer
+		}
+	}
+
+	if len(j.config.Audiences) > 0 {
+		audiences := []string{}
+		switch aud := claims["aud"].(type) {
+		case string:
+			audiences = append(audiences, aud)
+		case []interface{}:
+			for _, item := range aud {
+				if value, ok := item.(string); ok {
+					audiences = append(audiences, value)
+				}
+			}
+		}
+
+		found := false
+		for _, audience := range audiences {
+			if stringInSlice(audience, j.config.Audiences) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrJWTInvalidAudience
+		}
+	}
+
+	for _, claim := range j.config.RequiredClaims {
+		if _, ok := claims[claim]; !ok {
+			return fmt.Errorf("%v: %s", ErrJWTMissingClaim, claim)
+		}
+	}
+	return
+}
+
+/*
+allowed returns whether algorithm is allowed
+*/
+func (j *JWTAuthorizer) allowed(algorithm string) bool {
+	return stringInSlice(algorithm, j.config.Algorithms)
+}
+
+/*
+algorithms returns algorithms of configured keys
+*/
+func (j *JWTAuthorizer) algorithms() (result []string) {
+	keys := append(append([]*jwtKey{}, j.keys...), j.jwks...)
+	for _, algorithm := range []string{JWT_HS256, JWT_RS256, JWT_ES256} {
+		for _, key := range keys {
+			if jwtKeyAlgorithm(key.key) == algorithm {
+				result = append(result, algorithm)
+				break
+			}
+		}
+	}
+	// keys can be added to jwks file later
+	if j.config.JWKSFile != "" {
+		for _, algorithm := range []string{JWT_RS256, JWT_ES256} {
+			if !stringInSlice(algorithm, result) {
+				result = append(result, algorithm)
+			}
+		}
+	}
+	return
+}
+
+/*
+candidates returns keys that can verify token with given key id. Keys from jwks
+are matched by key id when token has one, other keys are always tried.
+*/
+func (j *JWTAuthorizer) candidates(kid string) (result []*jwtKey) {
+	result = append(result, j.keys...)
+
+	if j.watcher == nil {
+		return
+	}
+
+	j.check(logger())
+
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	for _, key := range j.jwks {
+		if kid == "" || key.id == "" || key.id == kid {
+			result = append(result, key)
+		}
+	}
+	return
+}
+
+/*
+check checks jwks file for changes when reload interval passed, reload is logged to given logger
+*/
+func (j *JWTAuthorizer) check(log Logger) {
+	if j.watcher == nil {
+		return
+	}
+
+	j.lock.Lock()
+	if time.Since(j.checked) < time.Duration(j.config.JWKSReloadInterval) {
+		j.lock.Unlock()
+		return
+	}
+	j.checked = time.Now()
+	j.lock.Unlock()
+
+	if len(j.watcher.Changed()) > 0 {
+		j.reloadJWKS(log)
+	}
+}
+
+/*
+reloadJWKS reloads keys from jwks file, on error old keys are kept
+*/
+func (j *JWTAuthorizer) reloadJWKS(log Logger) {
+	keys, err := readJWKSFile(j.config.JWKSFile)
+	if err != nil {
+		log.Errorf("Reload of jwks file %s failed, keeping old keys: %v", j.config.JWKSFile, err)
+		return
+	}
+
+	j.lock.Lock()
+	j.jwks = keys
+	j.lock.Unlock()
+
+	log.Infof("Loaded %d keys from jwks file %s", len(keys), j.config.JWKSFile)
+}
+
+/*
+SetRequestClaims adds verified claims of request, they are available to tasks as {{.claims}}
+*/
+func SetRequestClaims(r *http.Request, claims map[string]interface{}) {
+	state := getRequestState(r.Context())
+	if state.Claims == nil {
+		state.Claims = map[string]interface{}{}
+	}
+	for key, value := range claims {
+		state.Claims[key] = value
+	}
+}
+
+/*
+decodeJWTPart decodes base64url encoded json part of token
+*/
+func decodeJWTPart(part string, target interface{}) error {
+	body, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return ErrJWTMalformed
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err = decoder.Decode(target); err != nil {
+		return ErrJWTMalformed
+	}
+	return nil
+}
+
+/*
+jwtTimeClaim returns time of numeric date claim, zero time if claim is missing
+*/
+func jwtTimeClaim(claims map[string]interface{}, name string) (result time.Time, err error) {
+	value, ok := claims[name]
+	if !ok {
+		return
+	}
+
+	number, ok := value.(json.Number)
+	if !ok {
+		return result, fmt.Errorf("%v: %s", ErrJWTInvalidTimeClaim, name)
+	}
+
+	// seconds outside of float precision cannot be converted to time
+	var seconds float64
+	if seconds, err = number.Float64(); err != nil || math.IsNaN(seconds) || math.Abs(seconds) > jwtMaxTimeClaim {
+		return result, fmt.Errorf("%v: %s", ErrJWTInvalidTimeClaim, name)
+	}
+
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second))), nil
+}
+
+/*
+jwtKeyAlgorithm returns algorithm that uses key
+*/
+func jwtKeyAlgorithm(key interface{}) string {
+	switch key := key.(type) {
+	case []byte:
+		return JWT_HS256
+	case *rsa.PublicKey:
+		return JWT_RS256
+	case *ecdsa.PublicKey:
+		if key.Curve == elliptic.P256() {
+			return JWT_ES256
+		}
+	}
+	return ""
+}
+
+/*
+readJWTKeyFile reads public key from pem file (public key or certificate)
+*/
+func readJWTKeyFile(filename string) (key interface{}, err error) {
+	var body []byte
+	if body, err = ioutil.ReadFile(filename); err != nil {
+		return
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.New("no pem data found")
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		var certificate *x509.Certificate
+		if certificate, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return
+		}
+		key = certificate.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return
+	}
+
+	if jwtKeyAlgorithm(key) == "" {
+		return nil, errors.New("unsupported key, RSA or EC P-256 public key expected")
+	}
+	return
+}
+
+/*
+readJWKSFile reads signing keys from jwks file (RSA, EC P-256 and oct keys)
+*/
+func readJWKSFile(filename string) (result []*jwtKey, err error) {
+	var body []byte
+	if body, err = ioutil.ReadFile(filename); err != nil {
+		return
+	}
+
+	jwks := struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			Use     string `json:"use"`
+			Curve   string `json:"crv"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+			X       string `json:"x"`
+			Y       string `json:"y"`
+			K       string `json:"k"`
+		} `json:"keys"`
+	}{}
+	if err = json.Unmarshal(body, &jwks); err != nil {
+		return
+	}
+
+	decode := func(value string) *big.Int {
+		b, e := base64.RawURLEncoding.DecodeString(value)
+		if e != nil || len(b) == 0 {
+			err = fmt.Errorf("invalid key parameter %q", value)
+			return new(big.Int)
+		}
+		return new(big.Int).SetBytes(b)
+	}
+
+	for i, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key interface{}
+		switch jwk.KeyType {
+		case "RSA":
+			key = &rsa.PublicKey{N: decode(jwk.N), E: int(decode(jwk.E).Int64())}
+		case "EC":
+			if jwk.Curve != "P-256" {
+				continue
+			}
+			public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: decode(jwk.X), Y: decode(jwk.Y)}
+			if err == nil && !public.Curve.IsOnCurve(public.X, public.Y) {
+				err = errors.New("point is not on curve")
+			}
+			key = public
+		case "oct":
+			var secret []byte
+			if secret, err = base64.RawURLEncoding.DecodeString(jwk.K); err == nil && len(secret) == 0 {
+				err = errors.New("empty secret")
+			}
+			key = secret
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %v", i, err)
+		}
+		result = append(result, &jwtKey{id: jwk.KeyID, key: key})
+	}
+	return
+}
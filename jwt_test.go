@@ -0,0 +1,184 @@
+package goexpose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+/*
+signJWT returns token signed by given key ([]byte, *rsa.PrivateKey or *ecdsa.PrivateKey)
+*/
+func signJWT(algorithm, kid string, key interface{}, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": algorithm, "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	case *ecdsa.PrivateKey:
+		r, s, _ := ecdsa.Sign(rand.Reader, key, hash[:])
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuthorizer(t *testing.T) {
+
+	newAuthorizer := func(config string) (*JWTAuthorizer, error) {
+		authorizer, err := JWTAuthorizerFactory(&AuthorizerConfig{Type: "jwt", Config: json.RawMessage(config)})
+		if err != nil {
+			return nil, err
+		}
+		return authorizer.(*JWTAuthorizer), nil
+	}
+
+	Convey("Test HS256 and claims", t, func() {
+		authorizer, err := newAuthorizer(`{"secret": "secret", "issuers": ["sso"], "audiences": ["goexpose"], "required_claims": ["role"], "leeway": "30s"}`)
+		So(err, ShouldBeNil)
+
+		now := time.Now()
+		claims := map[string]interface{}{
+			"sub": "user", "iss": "sso", "aud": []string{"other", "goexpose"}, "role": "admin",
+			"exp": now.Add(-10 * time.Second).Unix(), "nbf": now.Add(10 * time.Second).Unix(),
+		}
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.Header.Set("Authorization", "Bearer "+signJWT(JWT_HS256, "", []byte("secret"), claims))
+		request = request.WithContext(withRequestState(request.Context(), &requestState{}))
+		So(authorizer.Authorize(request), ShouldBeNil)
+
+		state := getRequestState(request.Context())
+		So(state.User, ShouldEqual, "user")
+		So(state.Claims["role"], ShouldEqual, "admin")
+
+		_, err = authorizer.Verify(signJWT(JWT_HS256, "", []byte("other"), claims), now)
+		So(err, ShouldEqual, ErrJWTSignature)
+
+		_, err = authorizer.Verify(signJWT(JWT_HS256, "", []byte("secret"), claims), now.Add(time.Minute))
+		So(err, ShouldEqual, ErrJWTExpired)
+
+		_, err = authorizer.Verify(signJWT(JWT_HS256, "", []byte("secret"), claims), now.Add(-time.Minute))
+		So(err, ShouldEqual, ErrJWTNotValidYet)
+
+		claims["iss"] = "unknown"
+		_, err = authorizer.Verify(signJWT(JWT_HS256, "", []byte("secret"), claims), now)
+		So(err, ShouldEqual, ErrJWTInvalidIssuer)
+
+		claims["iss"], claims["aud"] = "sso", "other"
+		_, err = authorizer.Verify(signJWT(JWT_HS256, "", []byte("secret"), claims), now)
+		So(err, ShouldEqual, ErrJWTInvalidAudience)
+
+		claims["aud"] = "goexpose"
+		delete(claims, "role")
+		_, err = authorizer.Verify(signJWT(JWT_HS256, "", []byte("secret"), claims), now)
+		So(err, ShouldNotBeNil)
+
+		_, err = authorizer.Verify(signJWT("none", "", []byte("secret"), claims), now)
+		So(err, ShouldEqual, ErrJWTAlgorithm)
+	})
+
+	Convey("Test time claims", t, func() {
+		authorizer, err := newAuthorizer(`{"secret": "secret"}`)
+		So(err, ShouldBeNil)
+
+		now := time.Unix(1500000000, 0)
+		verify := func(claims map[string]interface{}) error {
+			_, err := authorizer.Verify(signJWT(JWT_HS256, "", []byte("secret"), claims), now)
+			return err
+		}
+
+		So(verify(map[string]interface{}{"exp": 1500000000.5}), ShouldBeNil)
+		So(verify(map[string]interface{}{"exp": 1499999999.5}), ShouldEqual, ErrJWTExpired)
+		So(verify(map[string]interface{}{"exp": float64(1 << 53), "iat": 1500000000}), ShouldBeNil)
+
+		// values that cannot be converted to time are rejected
+		for _, claims := range []map[string]interface{}{
+			{"exp": 1e300},
+			{"exp": float64(1<<53) * 2},
+			{"nbf": -1e300},
+			{"iat": 1e20},
+			{"exp": "1500000000"},
+			{"iat": json.RawMessage("1e400")},
+		} {
+			err = verify(claims)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, ErrJWTInvalidTimeClaim.Error())
+		}
+	})
+
+	Convey("Test RS256 and ES256 with jwks file", t, func() {
+		directory, err := ioutil.TempDir("", "goexpose")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(directory)
+
+		rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+		encode := func(b []byte) string {
+			return base64.RawURLEncoding.EncodeToString(b)
+		}
+		jwks := map[string]interface{}{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "n": encode(rsaKey.N.Bytes()), "e": encode([]byte{1, 0, 1})},
+		}}
+		filename := filepath.Join(directory, "jwks.json")
+		body, _ := json.Marshal(jwks)
+		So(ioutil.WriteFile(filename, body, 0644), ShouldBeNil)
+
+		authorizer, err := newAuthorizer(`{"jwks_file": "` + filename + `", "jwks_reload_interval": 1}`)
+		So(err, ShouldBeNil)
+		So(authorizer.config.Algorithms, ShouldResemble, []string{JWT_RS256, JWT_ES256})
+
+		claims := map[string]interface{}{"sub": "user"}
+		_, err = authorizer.Verify(signJWT(JWT_RS256, "rsa", rsaKey, claims), time.Now())
+		So(err, ShouldBeNil)
+
+		// ec key is not in jwks file yet
+		token := signJWT(JWT_ES256, "ec", ecKey, claims)
+		_, err = authorizer.Verify(token, time.Now())
+		So(err, ShouldEqual, ErrJWTSignature)
+
+		jwks["keys"] = append(jwks["keys"].([]map[string]string), map[string]string{
+			"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X.Bytes()), "y": encode(ecKey.Y.Bytes()),
+		})
+		body, _ = json.Marshal(jwks)
+		So(ioutil.WriteFile(filename, body, 0644), ShouldBeNil)
+
+		authorizer.checked = time.Time{}
+		_, err = authorizer.Verify(token, time.Now())
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Test invalid config", t, func() {
+		_, err := newAuthorizer(`{}`)
+		So(err, ShouldNotBeNil)
+
+		_, err = newAuthorizer(`{"secret": "s", "algorithms": ["HS512"]}`)
+		So(err, ShouldNotBeNil)
+
+		_, err = newAuthorizer(`{"key_files": ["/nonexistent.pem"]}`)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,419 @@
+package goexpose
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/*
+Security linter
+
+Linter finds dangerous endpoint configurations: request values interpolated to
+shell commands or file paths without regexp that restricts them, endpoints
+without authorizers, responses that contain environment or queries and basic
+credentials sent over plain http. Findings are printed by lint command and
+checked when server starts (or configuration is reloaded).
+*/
+
+const (
+	LINT_WARNING = "warning"
+	LINT_ERROR   = "error"
+
+	// linting is not run at startup
+	LINT_OFF = "off"
+)
+
+var (
+	// severity of findings of rules
+	lintRules = map[string]string{
+		"shell-input":          LINT_ERROR,
+		"unconstrained-var":    LINT_ERROR,
+		"return-params":        LINT_ERROR,
+		"no-authorizers":       LINT_WARNING,
+		"plaintext-basic-auth": LINT_WARNING,
+		"return-queries":       LINT_WARNING,
+	}
+
+	lintSeverities = map[string]int{
+		LINT_WARNING: 1,
+		LINT_ERROR:   2,
+	}
+
+	// references to request values in templates ({{.query.name}}, {{index .url "id"}})
+	lintQueryRegex = regexp.MustCompile(`\.query\.(\w+)|index\s+\.query\s+"([^"]+)"`)
+	lintURLRegex   = regexp.MustCompile(`\.url\.(\w+)|index\s+\.url\s+"([^"]+)"`)
+	lintBodyRegex  = regexp.MustCompile(`\.request\.body\b`)
+	lintEnvRegex   = regexp.MustCompile(`\.env\b`)
+
+	// credentials in connection url (user:password@ or password=)
+	lintCredentialsRegex = regexp.MustCompile(`^[^/@]*:[^/@]*@|://[^/@]*:[^/@]*@|password=\S+`)
+
+	// values that regexp of url variable must not match to be safe in shell commands and file paths
+	lintUnsafeValues = []string{"..", "../etc", "a;b", "a b", "a|b", "a&b", "$(a)", "`a`", "a'b", "a\"b", "a\nb"}
+
+	// authorizers that receive credentials in basic authorization header
+	lintBasicAuthorizers = map[string]bool{
+		"basic": true,
+		"ldap":  true,
+	}
+)
+
+/*
+LintConfig is configuration of linting at startup
+*/
+type LintConfig struct {
+	// findings with this or higher severity (warning, error) stop server from
+	// starting, other findings are logged. Default is to log all findings, "off"
+	// disables linting at startup.
+	Severity string `json:"severity"`
+
+	// rules that are not checked
+	Ignore []string `json:"ignore"`
+}
+
+/*
+Validate validates lint configuration
+*/
+func (l *LintConfig) Validate() (err error) {
+	l.Severity = strings.TrimSpace(strings.ToLower(l.Severity))
+	if _, ok := lintSeverities[l.Severity]; !ok && l.Severity != "" && l.Severity != LINT_OFF {
+		return fmt.Errorf("lint: unknown severity %s", l.Severity)
+	}
+	for _, rule := range l.Ignore {
+		if _, ok := lintRules[rule]; !ok {
+			return fmt.Errorf("lint: unknown rule %s", rule)
+		}
+	}
+	return
+}
+
+/*
+LintFinding is dangerous configuration found by linter
+*/
+type LintFinding struct {
+	*ConfigError
+
+	Rule     string
+	Severity string
+}
+
+func (l *LintFinding) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", l.Severity, l.ConfigError.Error(), l.Rule)
+}
+
+/*
+Lint validates configuration and returns findings of all rules that are not
+ignored, errors are returned when configuration is invalid.
+*/
+func (s *Server) Lint() (findings []*LintFinding, err error) {
+	if _, err = s.routes(); err != nil {
+		return
+	}
+
+	l := &linter{
+		config:  s.Config,
+		ignored: map[string]bool{},
+	}
+	if s.Config.Lint != nil {
+		for _, rule := range s.Config.Lint.Ignore {
+			l.ignored[rule] = true
+		}
+	}
+
+	for i, ec := range s.Config.Endpoints {
+		l.endpoint(i, ec)
+	}
+	l.listeners()
+
+	return l.findings, nil
+}
+
+/*
+checkLint lints configuration when server starts or configuration is reloaded.
+Findings with configured or higher severity are returned, other are logged.
+*/
+func (s *Server) checkLint() error {
+	severity := ""
+	if s.Config.Lint != nil {
+		severity = s.Config.Lint.Severity
+	}
+	if severity == LINT_OFF {
+		return nil
+	}
+
+	findings, err := s.Lint()
+	if err != nil {
+		return err
+	}
+
+	errs := ConfigErrors{}
+	for _, finding := range findings {
+		if severity != "" && lintSeverities[finding.Severity] >= lintSeverities[severity] {
+			errs.Add(finding)
+		} else {
+			logger().Warningf("Lint %v", finding)
+		}
+	}
+	return errs.Err()
+}
+
+/*
+lintQuery is query of postgres, mysql, cassandra or redis task
+*/
+type lintQuery struct {
+	URL     string   `json:"url"`
+	Query   string   `json:"query"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+/*
+linter collects findings of configuration
+*/
+type linter struct {
+	config   *Config
+	ignored  map[string]bool
+	findings []*LintFinding
+}
+
+/*
+add adds finding of rule, unless rule is ignored
+*/
+func (l *linter) add(rule string, source configSource, pointer, endpoint, method, format string, args ...interface{}) {
+	if l.ignored[rule] {
+		return
+	}
+
+	err := source.Wrap(fmt.Errorf(format, args...), pointer).(*ConfigError)
+	err.Endpoint, err.Method = endpoint, method
+
+	l.findings = append(l.findings, &LintFinding{
+		ConfigError: err,
+		Rule:        rule,
+		Severity:    lintRules[rule],
+	})
+}
+
+/*
+endpoint lints all methods of endpoint
+*/
+func (l *linter) endpoint(index int, ec *EndpointConfig) {
+	if ec == nil {
+		return
+	}
+	source := l.config.endpointSource(index)
+
+	// url variables by their regexp (empty when not given)
+	vars := map[string]string{}
+	_, parameters := openAPIPath(ec.Path)
+	for _, parameter := range parameters {
+		pattern, _ := parameter["schema"].(Schema)["pattern"].(string)
+		vars[parameter["name"].(string)] = pattern
+	}
+
+	methods := make([]string, 0, len(ec.Methods))
+	for method := range ec.Methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	for _, method := range methods {
+		tc := ec.Methods[method]
+		pointer := "/methods/" + escapePointer(method)
+
+		if len(ec.Authorizers) == 0 && len(tc.Authorizers) == 0 {
+			l.add("no-authorizers", source, pointer, ec.Path, method, "endpoint has no authorizers")
+		}
+
+		// params contain all environment variables
+		if ec.QueryParams != nil && ec.QueryParams.ReturnParams {
+			l.add("return-params", source, "/query_params/return_params", ec.Path, method, "return_params returns environment variables in response")
+		} else if tc.QueryParams != nil && tc.QueryParams.ReturnParams {
+			l.add("return-params", source, pointer+"/query_params/return_params", ec.Path, method, "return_params returns environment variables in response")
+		}
+
+		// query params of endpoint are used instead of params of method
+		qp := ec.QueryParams
+		if qp == nil {
+			qp = tc.QueryParams
+		}
+		params := map[string]string{}
+		if qp != nil {
+			for _, param := range qp.Params {
+				if param != nil {
+					params[param.Name] = param.Regexp
+				}
+			}
+		}
+
+		l.task(source, ec.Path, method, pointer, &tc, params, vars)
+	}
+}
+
+/*
+task lints config of task, subtasks of multi task are linted too
+*/
+func (l *linter) task(source configSource, path, method, pointer string, tc *TaskConfig, params, vars map[string]string) {
+	switch tc.Type {
+	case "shell":
+		config := NewShellTaskConfig()
+		if json.Unmarshal(tc.Config, config) != nil {
+			return
+		}
+		for i, command := range config.Commands {
+			if command == nil {
+				continue
+			}
+			p := fmt.Sprintf("%s/config/commands/%d/command", pointer, i)
+
+			if lintBodyRegex.MatchString(command.Command) {
+				l.add("shell-input", source, p, path, method, "shell command interpolates request body")
+			}
+			for _, name := range lintReferences(lintQueryRegex, command.Command) {
+				if re, ok := params[name]; ok && re == "" {
+					l.add("shell-input", source, p, path, method, "shell command interpolates query param %s without regexp", name)
+				}
+			}
+			l.urlVars(source, path, method, p, "shell command", command.Command, vars)
+		}
+	case "filesystem":
+		config := NewFilesystemConfig()
+		if json.Unmarshal(tc.Config, config) != nil {
+			return
+		}
+		l.urlVars(source, path, method, pointer+"/config/file", "file path", config.File, vars)
+		l.urlVars(source, path, method, pointer+"/config/directory", "directory", config.Directory, vars)
+	case "postgres", "mysql", "cassandra", "redis":
+		config := struct {
+			ReturnQueries bool        `json:"return_queries"`
+			Address       string      `json:"address"`
+			Queries       []lintQuery `json:"queries"`
+		}{}
+		if json.Unmarshal(tc.Config, &config) != nil || !config.ReturnQueries {
+			return
+		}
+		p := pointer + "/config/return_queries"
+		urls := []string{config.Address}
+		for _, query := range config.Queries {
+			urls = append(urls, query.URL)
+		}
+		for _, url := range urls {
+			if lintCredentialsRegex.MatchString(url) {
+				l.add("return-queries", source, p, path, method, "return_queries is enabled on task with credentials in connection url, use named connection")
+				return
+			}
+		}
+		for _, query := range config.Queries {
+			for _, value := range append([]string{query.Query, query.Command}, query.Args...) {
+				if lintEnvRegex.MatchString(value) {
+					l.add("return-queries", source, p, path, method, "return_queries returns queries with environment variables")
+					return
+				}
+			}
+		}
+	case "multi":
+		config := &MultiTaskConfig{}
+		if json.Unmarshal(tc.Config, config) != nil {
+			return
+		}
+		for i, subtask := range config.Tasks {
+			if subtask == nil {
+				continue
+			}
+			st := *subtask
+			if l.config.extendTask(&st) != nil {
+				continue
+			}
+			l.task(source, path, method, fmt.Sprintf("%s/config/tasks/%d", pointer, i), &st, params, vars)
+		}
+	}
+}
+
+/*
+urlVars adds findings for url variables used in value that are not restricted
+by regexp (any value that could escape shell or directory is allowed).
+*/
+func (l *linter) urlVars(source configSource, path, method, pointer, usage, value string, vars map[string]string) {
+	for _, name := range lintReferences(lintURLRegex, value) {
+		pattern, ok := vars[name]
+		if !ok {
+			continue
+		}
+		if pattern == "" {
+			l.add("unconstrained-var", source, pointer, path, method, "url variable %s is used in %s without regexp", name, usage)
+			continue
+		}
+		if re, err := regexp.Compile(pattern); err == nil {
+			for _, unsafe := range lintUnsafeValues {
+				if re.MatchString(unsafe) {
+					l.add("unconstrained-var", source, pointer, path, method, "url variable %s is used in %s, its regexp allows %q", name, usage, unsafe)
+					break
+				}
+			}
+		}
+	}
+}
+
+/*
+listeners adds findings for basic credentials sent over plain http listeners.
+Loopback listeners (e.g. behind proxy) are not reported.
+*/
+func (l *linter) listeners() {
+	for _, lc := range l.config.GetListeners() {
+		if lc.SSL != nil || lc.Network == "unix" || lc.Redirect != "" {
+			continue
+		}
+		if ip := net.ParseIP(lc.Host); lc.Host == "localhost" || ip != nil && ip.IsLoopback() {
+			continue
+		}
+
+		allowed := map[string]bool{}
+		for _, path := range lc.Endpoints {
+			allowed[(&EndpointConfig{Path: path}).RouteName()] = true
+		}
+
+		reported := map[string]bool{}
+		for _, ec := range l.config.Endpoints {
+			if ec == nil || len(allowed) > 0 && !allowed[ec.RouteName()] {
+				continue
+			}
+
+			names := append([]string{}, ec.Authorizers...)
+			methods := make([]string, 0, len(ec.Methods))
+			for method := range ec.Methods {
+				methods = append(methods, method)
+			}
+			sort.Strings(methods)
+			for _, method := range methods {
+				names = append(names, ec.Methods[method].Authorizers...)
+			}
+
+			for _, name := range names {
+				ac := l.config.Authorizers[name]
+				if ac == nil || reported[name] || !lintBasicAuthorizers[ac.Type] {
+					continue
+				}
+				reported[name] = true
+				l.add("plaintext-basic-auth", l.config.authorizerSource(name), "", "", "", "authorizer %s receives credentials over plain http listener %s", name, lc)
+			}
+		}
+	}
+}
+
+/*
+lintReferences returns names referenced in value by regexp with two alternative groups
+*/
+func lintReferences(re *regexp.Regexp, value string) (result []string) {
+	for _, match := range re.FindAllStringSubmatch(value, -1) {
+		if match[1] != "" {
+			result = append(result, match[1])
+		} else {
+			result = append(result, match[2])
+		}
+	}
+	return
+}
@@ -0,0 +1,78 @@
+package goexpose
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLint(t *testing.T) {
+
+	lint := func(config *Config) map[string]int {
+		server, err := NewServer(config)
+		So(err, ShouldBeNil)
+
+		findings, err := server.Lint()
+		So(err, ShouldBeNil)
+
+		rules := map[string]int{}
+		for _, finding := range findings {
+			rules[finding.Rule]++
+		}
+		return rules
+	}
+
+	Convey("Test shell input", t, func() {
+		config := NewConfig()
+		config.Host = "127.0.0.1"
+		config.Endpoints = []*EndpointConfig{{
+			Path: "/run/{name}/{id:[0-9]+}",
+			QueryParams: &QueryParams{Params: []*QueryParamsConfigParam{
+				{Name: "q"}, {Name: "safe", Regexp: "^[a-z]+$"},
+			}},
+			Methods: map[string]TaskConfig{
+				"POST": {Type: "shell", Config: json.RawMessage(`{"commands": [
+					{"command": "echo {{.url.name}} {{.url.id}} {{.query.q}} {{.query.safe}}"},
+					{"command": "echo {{.request.body}}"}
+				]}`)},
+			},
+		}}
+
+		rules := lint(config)
+		So(rules["shell-input"], ShouldEqual, 2)
+		So(rules["unconstrained-var"], ShouldEqual, 1)
+		So(rules["no-authorizers"], ShouldEqual, 1)
+		So(rules["plaintext-basic-auth"], ShouldEqual, 0)
+
+		config.Lint = &LintConfig{Ignore: []string{"no-authorizers"}}
+		So(lint(config)["no-authorizers"], ShouldEqual, 0)
+	})
+
+	Convey("Test filesystem and plain http", t, func() {
+		config := NewConfig()
+		config.Authorizers = map[string]*AuthorizerConfig{
+			"basic": {Type: "basic", Config: json.RawMessage(`{"username": "u", "password": "p"}`)},
+		}
+		config.Endpoints = []*EndpointConfig{{
+			Path:        "/files/{file:.+}",
+			Authorizers: []string{"basic"},
+			QueryParams: &QueryParams{ReturnParams: true},
+			Methods: map[string]TaskConfig{
+				"GET": {Type: "filesystem", Config: json.RawMessage(`{"directory": "/tmp", "file": "{{.url.file}}"}`)},
+			},
+		}}
+
+		rules := lint(config)
+		So(rules["unconstrained-var"], ShouldEqual, 1)
+		So(rules["return-params"], ShouldEqual, 1)
+		So(rules["plaintext-basic-auth"], ShouldEqual, 1)
+		So(rules["no-authorizers"], ShouldEqual, 0)
+	})
+
+	Convey("Test lint config", t, func() {
+		So((&LintConfig{Severity: "Error"}).Validate(), ShouldBeNil)
+		So((&LintConfig{Severity: "fatal"}).Validate(), ShouldNotBeNil)
+		So((&LintConfig{Ignore: []string{"unknown"}}).Validate(), ShouldNotBeNil)
+	})
+}
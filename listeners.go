@@ -0,0 +1,308 @@
+package goexpose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+ListenerConfig is configuration of single listener. Goexpose can listen on
+multiple addresses (tcp, unix sockets) with and without ssl at once.
+*/
+type ListenerConfig struct {
+	// tcp (default) or unix
+	Network string `json:"network"`
+
+	// tcp host and port
+	Host string `json:"host"`
+	Port int    `json:"port"`
+
+	// unix socket path and its permissions (e.g. "0660")
+	Socket     string `json:"socket"`
+	SocketMode string `json:"socket_mode"`
+
+	// ssl settings, if not given plain http is served
+	SSL *SSLConfig `json:"ssl"`
+
+	// redirect all requests to given base url (e.g. "https://example.com:9443"),
+	// value "https" redirects to first https listener on the same host
+	Redirect string `json:"redirect"`
+
+	// paths of endpoints that are reachable on this listener, if empty all endpoints are reachable
+	Endpoints []string `json:"endpoints"`
+}
+
+/*
+Validate validates listener config
+*/
+func (l *ListenerConfig) Validate() (err error) {
+	l.Network = strings.TrimSpace(l.Network)
+	if l.Network == "" {
+		l.Network = "tcp"
+	}
+
+	switch l.Network {
+	case "tcp":
+		if l.Port <= 0 || l.Port > 65535 {
+			return fmt.Errorf("listener %s: invalid port %d", l, l.Port)
+		}
+	case "unix":
+		if l.Socket = strings.TrimSpace(l.Socket); l.Socket == "" {
+			return errors.New("unix listener: please provide socket")
+		}
+		if l.SocketMode != "" {
+			if _, err = strconv.ParseUint(l.SocketMode, 8, 32); err != nil {
+				return fmt.Errorf("listener %s: invalid socket_mode %s", l, l.SocketMode)
+			}
+		}
+	default:
+		return fmt.Errorf("listener: unknown network %s", l.Network)
+	}
+
+	if l.SSL != nil {
+		if err = l.SSL.Validate(); err != nil {
+			return fmt.Errorf("listener %s: %v", l, err)
+		}
+	}
+
+	l.Redirect = strings.TrimSpace(l.Redirect)
+	if l.Redirect != "" && l.Redirect != "https" {
+		if _, err = url.Parse(l.Redirect); err != nil {
+			return fmt.Errorf("listener %s: invalid redirect %v", l, err)
+		}
+	}
+
+	return
+}
+
+/*
+Address returns address for net.Listen
+*/
+func (l *ListenerConfig) Address() string {
+	if l.Network == "unix" {
+		return l.Socket
+	}
+	return net.JoinHostPort(l.Host, strconv.Itoa(l.Port))
+}
+
+/*
+String returns url like representation of listener
+*/
+func (l *ListenerConfig) String() string {
+	scheme := "http"
+	if l.SSL != nil {
+		scheme = "https"
+	}
+	if l.Network == "unix" {
+		return fmt.Sprintf("%s+unix://%s", scheme, l.Socket)
+	}
+	return fmt.Sprintf("%s://%s", scheme, l.Address())
+}
+
+/*
+GetListeners returns configured listeners, if no listeners are configured
+listener is created from host, port and ssl.
+*/
+func (c *Config) GetListeners() []*ListenerConfig {
+	if len(c.Listeners) > 0 {
+		return c.Listeners
+	}
+
+	return []*ListenerConfig{{
+		Network: "tcp",
+		Host:    c.Host,
+		Port:    c.Port,
+		SSL:     c.SSL,
+	}}
+}
+
+/*
+ValidateListeners validates all listeners
+*/
+func (c *Config) ValidateListeners() (err error) {
+	listeners := c.GetListeners()
+	seen := map[string]bool{}
+	for _, listener := range listeners {
+		if err = listener.Validate(); err != nil {
+			return
+		}
+		address := listener.Network + ":" + listener.Address()
+		if seen[address] {
+			return fmt.Errorf("listener %s defined multiple times", listener)
+		}
+		seen[address] = true
+
+		if listener.Redirect == "https" && c.httpsListener() == nil {
+			return fmt.Errorf("listener %s: redirect to https but no https listener defined", listener)
+		}
+	}
+	return
+}
+
+/*
+httpsListener returns first tcp listener with ssl
+*/
+func (c *Config) httpsListener() *ListenerConfig {
+	for _, listener := range c.GetListeners() {
+		if listener.SSL != nil && listener.Network != "unix" {
+			return listener
+		}
+	}
+	return nil
+}
+
+/*
+listen starts listening on given listener and returns http server for it
+*/
+func (s *Server) listen(lc *ListenerConfig) (server *http.Server, ln net.Listener, err error) {
+	if lc.Network == "unix" {
+		// remove stale socket
+		if info, e := os.Stat(lc.Socket); e == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(lc.Socket)
+		}
+	}
+
+	if ln, err = net.Listen(lc.Network, lc.Address()); err != nil {
+		return
+	}
+
+	if lc.Network == "unix" && lc.SocketMode != "" {
+		mode, _ := strconv.ParseUint(lc.SocketMode, 8, 32)
+		if err = os.Chmod(lc.Socket, os.FileMode(mode)); err != nil {
+			ln.Close()
+			return
+		}
+	}
+
+	server = &http.Server{
+		Handler: s.instrument(s.listenerHandler(lc)),
+		BaseContext: func(net.Listener) context.Context {
+			return s.ctx
+		},
+	}
+
+	if lc.SSL != nil {
+		var reloader *certReloader
+		if server.TLSConfig, err = lc.SSL.TLSConfig(); err == nil {
+			reloader, err = newCertReloader(lc.SSL)
+		}
+		if err != nil {
+			ln.Close()
+			return
+		}
+
+		// certificate is reloaded when files change
+		server.TLSConfig.GetCertificate = reloader.GetCertificate
+		server.RegisterOnShutdown(reloader.Stop)
+	}
+	return
+}
+
+/*
+serve serves http server on listener
+*/
+func (s *Server) serve(lc *ListenerConfig, server *http.Server, ln net.Listener) error {
+	logger().Infof("Start listen on %s", lc)
+	if lc.SSL != nil {
+		// certificate is provided by tls config
+		return server.ServeTLS(ln, "", "")
+	}
+	return server.Serve(ln)
+}
+
+/*
+listenerHandler returns handler for given listener. It handles redirects and
+restriction of endpoints.
+*/
+func (s *Server) listenerHandler(lc *ListenerConfig) http.Handler {
+	if lc.Redirect != "" {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.redirect(lc, w, r)
+		})
+	}
+
+	if len(lc.Endpoints) == 0 {
+		return s
+	}
+
+	// routes are named by endpoint path
+	allowed := map[string]bool{}
+	for _, path := range lc.Endpoints {
+		allowed[(&EndpointConfig{Path: path}).RouteName()] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.RLock()
+		router := s.Router
+		s.lock.RUnlock()
+
+		var match mux.RouteMatch
+		if router.Match(r, &match) && !allowed[match.Route.GetName()] {
+			s.NotFoundHandler(w, r)
+			return
+		}
+
+		router.ServeHTTP(w, r)
+	})
+}
+
+/*
+redirect redirects request to configured url
+*/
+func (s *Server) redirect(lc *ListenerConfig, w http.ResponseWriter, r *http.Request) {
+	target := lc.Redirect
+
+	if target == "https" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+
+		https := s.GetConfig().httpsListener()
+		if https == nil {
+			s.NotFoundHandler(w, r)
+			return
+		}
+		target = "https://" + host
+		if https.Port != 443 {
+			target = "https://" + net.JoinHostPort(host, strconv.Itoa(https.Port))
+		}
+	}
+
+	http.Redirect(w, r, strings.TrimRight(target, "/")+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+/*
+shutdownServers calls Shutdown on all servers concurrently and returns first error.
+*/
+func shutdownServers(ctx context.Context, servers []*http.Server) (err error) {
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+	)
+
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			if e := server.Shutdown(ctx); e != nil {
+				lock.Lock()
+				err = e
+				lock.Unlock()
+			}
+		}(server)
+	}
+
+	wg.Wait()
+	return
+}
@@ -0,0 +1,490 @@
+package goexpose
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+/*
+Logging module
+
+By default goexpose logs through glog (configured by glog flags). When "logging"
+section is configured, log lines are written in text or json format to stderr
+or to file that is rotated by size.
+*/
+
+var (
+	// supported log levels
+	logLevels = map[string]int{
+		"debug":   logLevelDebug,
+		"info":    logLevelInfo,
+		"warning": logLevelWarning,
+		"error":   logLevelError,
+	}
+
+	// current logger
+	currentLogger     Logger = glogLogger{}
+	currentLoggerLock sync.RWMutex
+)
+
+const (
+	logLevelDebug = iota
+	logLevelInfo
+	logLevelWarning
+	logLevelError
+)
+
+/*
+Logger logs messages and access records
+*/
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+
+	// Access logs handled request
+	Access(record *AccessRecord)
+}
+
+/*
+AccessRecord is information about handled request
+*/
+type AccessRecord struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Route      string    `json:"route,omitempty"`
+	RouteName  string    `json:"route_name,omitempty"`
+	TaskType   string    `json:"task_type,omitempty"`
+	User       string    `json:"user,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	RequestID  string    `json:"request_id"`
+	TraceID    string    `json:"trace_id"`
+	SpanID     string    `json:"span_id"`
+
+	// duration in seconds
+	Duration float64 `json:"duration"`
+}
+
+/*
+logger returns current logger
+*/
+func logger() Logger {
+	currentLoggerLock.RLock()
+	defer currentLoggerLock.RUnlock()
+	return currentLogger
+}
+
+/*
+requestLogger returns logger that adds request id from request state in context
+to every log line, when context has no request id current logger is returned.
+*/
+func requestLogger(ctx context.Context) Logger {
+	l := logger()
+	if id := getRequestState(ctx).RequestID; id != "" {
+		return &requestIDLogger{Logger: l, requestID: id}
+	}
+	return l
+}
+
+/*
+SetLogger sets logger used by goexpose, previous logger is closed if it
+implements io.Closer.
+*/
+func SetLogger(l Logger) {
+	currentLoggerLock.Lock()
+	old := currentLogger
+	currentLogger = l
+	currentLoggerLock.Unlock()
+
+	if closer, ok := old.(io.Closer); ok && old != l {
+		closer.Close()
+	}
+}
+
+/*
+LoggingConfig is configuration of logging
+*/
+type LoggingConfig struct {
+	// minimal level of logged messages: debug, info (default), warning, error
+	Level string `json:"level"`
+
+	// text (default) or json
+	Format string `json:"format"`
+
+	// log file, if empty logs are written to stderr
+	File string `json:"file"`
+
+	// log file is rotated when it reaches this size in megabytes
+	MaxSize int `json:"max_size"`
+
+	// number of rotated files to keep
+	MaxBackups int `json:"max_backups"`
+}
+
+/*
+Validate validates logging configuration
+*/
+func (l *LoggingConfig) Validate() (err error) {
+	l.Level = strings.TrimSpace(strings.ToLower(l.Level))
+	if l.Level == "" {
+		l.Level = "info"
+	}
+	if _, ok := logLevels[l.Level]; !ok {
+		return fmt.Errorf("logging: unknown level %s", l.Level)
+	}
+
+	l.Format = strings.TrimSpace(strings.ToLower(l.Format))
+	if l.Format == "" {
+		l.Format = "text"
+	}
+	if l.Format != "text" && l.Format != "json" {
+		return fmt.Errorf("logging: unknown format %s", l.Format)
+	}
+
+	l.File = strings.TrimSpace(l.File)
+	if l.MaxSize < 0 || l.MaxBackups < 0 {
+		return fmt.Errorf("logging: invalid rotation settings")
+	}
+	if l.MaxSize == 0 {
+		l.MaxSize = DEFAULT_LOG_MAX_SIZE
+	}
+	if l.MaxBackups == 0 {
+		l.MaxBackups = DEFAULT_LOG_MAX_BACKUPS
+	}
+	return
+}
+
+/*
+NewLogger returns logger for given configuration, if config is nil glog is used.
+*/
+func NewLogger(config *LoggingConfig) (result Logger, err error) {
+	if config == nil {
+		return glogLogger{}, nil
+	}
+
+	var out io.Writer = os.Stderr
+	if config.File != "" {
+		if out, err = newRotatingFile(config.File, int64(config.MaxSize)*1024*1024, config.MaxBackups); err != nil {
+			return
+		}
+	}
+
+	result = &writerLogger{
+		out:   out,
+		level: logLevels[config.Level],
+		json:  config.Format == "json",
+	}
+	return
+}
+
+/*
+setupLogging validates logging configuration and sets logger
+*/
+func setupLogging(config *LoggingConfig) (err error) {
+	var l Logger
+	if l, err = loggerFromConfig(config); err != nil {
+		return
+	}
+
+	SetLogger(l)
+	return
+}
+
+/*
+loggerFromConfig validates logging configuration and returns logger, logger is
+not set.
+*/
+func loggerFromConfig(config *LoggingConfig) (result Logger, err error) {
+	if config != nil {
+		if err = config.Validate(); err != nil {
+			return
+		}
+	}
+	return NewLogger(config)
+}
+
+/*
+glogLogger logs through glog, debug messages and access records are logged with
+verbosity 2 and 1.
+*/
+type glogLogger struct{}
+
+func (glogLogger) Debugf(format string, args ...interface{}) {
+	glog.V(2).Infof(format, args...)
+}
+
+func (glogLogger) Infof(format string, args ...interface{}) {
+	glog.InfoDepth(1, fmt.Sprintf(format, args...))
+}
+
+func (glogLogger) Warningf(format string, args ...interface{}) {
+	glog.WarningDepth(1, fmt.Sprintf(format, args...))
+}
+
+func (glogLogger) Errorf(format string, args ...interface{}) {
+	glog.ErrorDepth(1, fmt.Sprintf(format, args...))
+}
+
+func (glogLogger) Access(record *AccessRecord) {
+	glog.V(1).Infof("%s %s %d %v request_id=%s", record.Method, record.Path, record.Status,
+		time.Duration(record.Duration*float64(time.Second)), record.RequestID)
+}
+
+/*
+writerLogger writes log lines in text or json format to writer
+*/
+type writerLogger struct {
+	lock  sync.Mutex
+	out   io.Writer
+	level int
+	json  bool
+}
+
+func (w *writerLogger) Debugf(format string, args ...interface{}) {
+	w.log(logLevelDebug, "debug", "", format, args...)
+}
+
+func (w *writerLogger) Infof(format string, args ...interface{}) {
+	w.log(logLevelInfo, "info", "", format, args...)
+}
+
+func (w *writerLogger) Warningf(format string, args ...interface{}) {
+	w.log(logLevelWarning, "warning", "", format, args...)
+}
+
+func (w *writerLogger) Errorf(format string, args ...interface{}) {
+	w.log(logLevelError, "error", "", format, args...)
+}
+
+/*
+log writes message with given level, request id is written when not empty
+*/
+func (w *writerLogger) log(level int, name string, requestID string, format string, args ...interface{}) {
+	if level < w.level {
+		return
+	}
+
+	now := time.Now()
+	message := fmt.Sprintf(format, args...)
+	if !w.json {
+		line := fmt.Sprintf("%s %s %s", now.Format(time.RFC3339Nano), strings.ToUpper(name), message)
+		if requestID != "" {
+			line += " request_id=" + requestID
+		}
+		w.write([]byte(line + "\n"))
+		return
+	}
+
+	record := map[string]interface{}{
+		"time":    now,
+		"level":   name,
+		"message": message,
+	}
+	if requestID != "" {
+		record["request_id"] = requestID
+	}
+	w.writeJSON(record)
+}
+
+/*
+Access writes access record, access records are logged with info level
+*/
+func (w *writerLogger) Access(record *AccessRecord) {
+	if logLevelInfo < w.level {
+		return
+	}
+
+	if w.json {
+		w.writeJSON(struct {
+			Level   string `json:"level"`
+			Message string `json:"message"`
+			*AccessRecord
+		}{"info", "access", record})
+		return
+	}
+
+	line := fmt.Sprintf("%s INFO %s %s %d %v bytes=%d", record.Time.Format(time.RFC3339Nano),
+		record.Method, record.Path, record.Status, time.Duration(record.Duration*float64(time.Second)), record.Bytes)
+
+	fields := [][2]string{
+		{"route", record.Route},
+		{"task", record.TaskType},
+		{"user", record.User},
+		{"remote", record.RemoteAddr},
+		{"request_id", record.RequestID},
+		{"trace_id", record.TraceID},
+	}
+	for _, field := range fields {
+		if field[1] != "" {
+			line += " " + field[0] + "=" + field[1]
+		}
+	}
+
+	w.write([]byte(line + "\n"))
+}
+
+/*
+requestIDLogger adds request id to messages of wrapped logger, writerLogger
+writes it as separate field.
+*/
+type requestIDLogger struct {
+	Logger
+	requestID string
+}
+
+func (r *requestIDLogger) Debugf(format string, args ...interface{}) {
+	r.log(logLevelDebug, "debug", format, args...)
+}
+
+func (r *requestIDLogger) Infof(format string, args ...interface{}) {
+	r.log(logLevelInfo, "info", format, args...)
+}
+
+func (r *requestIDLogger) Warningf(format string, args ...interface{}) {
+	r.log(logLevelWarning, "warning", format, args...)
+}
+
+func (r *requestIDLogger) Errorf(format string, args ...interface{}) {
+	r.log(logLevelError, "error", format, args...)
+}
+
+func (r *requestIDLogger) log(level int, name string, format string, args ...interface{}) {
+	if w, ok := r.Logger.(*writerLogger); ok {
+		w.log(level, name, r.requestID, format, args...)
+		return
+	}
+
+	message := fmt.Sprintf(format, args...) + " request_id=" + r.requestID
+	switch level {
+	case logLevelDebug:
+		r.Logger.Debugf("%s", message)
+	case logLevelInfo:
+		r.Logger.Infof("%s", message)
+	case logLevelWarning:
+		r.Logger.Warningf("%s", message)
+	default:
+		r.Logger.Errorf("%s", message)
+	}
+}
+
+func (w *writerLogger) writeJSON(value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	w.write(append(body, '\n'))
+}
+
+func (w *writerLogger) write(line []byte) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.out.Write(line)
+}
+
+/*
+Close closes log file
+*/
+func (w *writerLogger) Close() error {
+	if file, ok := w.out.(*rotatingFile); ok {
+		return file.Close()
+	}
+	return nil
+}
+
+/*
+newRotatingFile opens log file for appending
+*/
+func newRotatingFile(filename string, maxSize int64, maxBackups int) (result *rotatingFile, err error) {
+	result = &rotatingFile{
+		filename:   filename,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err = result.open(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+/*
+rotatingFile is file that is rotated when it reaches max size. Rotated files
+are renamed to filename.1, filename.2, ... and oldest files are removed.
+*/
+type rotatingFile struct {
+	lock       sync.Mutex
+	filename   string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+/*
+open opens log file, existing file is appended
+*/
+func (r *rotatingFile) open() (err error) {
+	if r.file, err = os.OpenFile(r.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return
+	}
+
+	var info os.FileInfo
+	if info, err = r.file.Stat(); err != nil {
+		r.file.Close()
+		return
+	}
+	r.size = info.Size()
+	return
+}
+
+/*
+rotate renames current file and opens new one
+*/
+func (r *rotatingFile) rotate() (err error) {
+	if err = r.file.Close(); err != nil {
+		return
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", r.filename, r.maxBackups))
+	for i := r.maxBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.filename, i), fmt.Sprintf("%s.%d", r.filename, i+1))
+	}
+	os.Rename(r.filename, r.filename+".1")
+
+	return r.open()
+}
+
+func (r *rotatingFile) Write(p []byte) (n int, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err = r.rotate(); err != nil {
+			return
+		}
+	}
+
+	n, err = r.file.Write(p)
+	r.size += int64(n)
+	return
+}
+
+/*
+Close closes log file
+*/
+func (r *rotatingFile) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.file.Close()
+}
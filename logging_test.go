@@ -0,0 +1,47 @@
+package goexpose
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRequestLogger(t *testing.T) {
+
+	Convey("Test request id in text format", t, func() {
+		buffer := &bytes.Buffer{}
+		SetLogger(&writerLogger{out: buffer})
+		defer SetLogger(glogLogger{})
+
+		ctx := withRequestState(context.Background(), &requestState{RequestID: "abc"})
+		requestLogger(ctx).Infof("hello %s", "world")
+		So(buffer.String(), ShouldEndWith, "INFO hello world request_id=abc\n")
+	})
+
+	Convey("Test request id in json format", t, func() {
+		buffer := &bytes.Buffer{}
+		SetLogger(&writerLogger{out: buffer, json: true})
+		defer SetLogger(glogLogger{})
+
+		ctx := withRequestState(context.Background(), &requestState{RequestID: "abc"})
+		requestLogger(ctx).Errorf("failed")
+
+		record := map[string]interface{}{}
+		So(json.Unmarshal(buffer.Bytes(), &record), ShouldBeNil)
+		So(record["message"], ShouldEqual, "failed")
+		So(record["level"], ShouldEqual, "error")
+		So(record["request_id"], ShouldEqual, "abc")
+	})
+
+	Convey("Test context without request id", t, func() {
+		buffer := &bytes.Buffer{}
+		l := &writerLogger{out: buffer}
+		SetLogger(l)
+		defer SetLogger(glogLogger{})
+
+		So(requestLogger(context.Background()), ShouldEqual, l)
+	})
+}
@@ -0,0 +1,396 @@
+package goexpose
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Metrics module
+
+Small implementation of prometheus metrics (counters, gauges and histograms
+with labels) and their text exposition format. Server exposes metrics on path
+configured in "metrics" section of configuration.
+*/
+
+var (
+	// default histogram buckets (in seconds), same as prometheus client uses
+	defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	// server metrics
+	metrics = newServerMetrics()
+)
+
+const (
+	metricCounter   = "counter"
+	metricGauge     = "gauge"
+	metricHistogram = "histogram"
+)
+
+/*
+MetricsConfig is configuration of metrics endpoint
+*/
+type MetricsConfig struct {
+	// path of metrics endpoint, default is /metrics
+	Path string `json:"path"`
+
+	// authorizers for metrics endpoint
+	Authorizers []string `json:"authorizers"`
+}
+
+/*
+Validate validates metrics configuration
+*/
+func (m *MetricsConfig) Validate() (err error) {
+	m.Path = strings.TrimSpace(m.Path)
+	if m.Path == "" {
+		m.Path = DEFAULT_METRICS_PATH
+	}
+	if !strings.HasPrefix(m.Path, "/") {
+		return fmt.Errorf("metrics: path %s must start with /", m.Path)
+	}
+	return
+}
+
+/*
+newServerMetrics creates and registers all metrics of server
+*/
+func newServerMetrics() *serverMetrics {
+	registry := newMetricsRegistry()
+	return &serverMetrics{
+		registry: registry,
+		requests: registry.Counter("goexpose_http_requests_total",
+			"Number of handled requests by route, method and status.", "route", "method", "status"),
+		requestDuration: registry.Histogram("goexpose_http_request_duration_seconds",
+			"Latency of handled requests by route and method.", defaultBuckets, "route", "method"),
+		requestsInFlight: registry.Gauge("goexpose_http_requests_in_flight",
+			"Number of requests being handled."),
+		taskDuration: registry.Histogram("goexpose_task_duration_seconds",
+			"Execution time of tasks by task type.", defaultBuckets, "type"),
+		tasksInFlight: registry.Gauge("goexpose_tasks_in_flight",
+			"Number of running tasks by task type.", "type"),
+		taskItemErrors: registry.Counter("goexpose_task_item_errors_total",
+			"Number of failed task items (commands, queries, urls) by task type, route and item.", "type", "route", "item"),
+		authorizationFailures: registry.Counter("goexpose_authorization_failures_total",
+			"Number of failed authorizations by authorizer name.", "authorizer"),
+	}
+}
+
+/*
+serverMetrics holds all metrics exposed by goexpose
+*/
+type serverMetrics struct {
+	registry              *metricsRegistry
+	requests              *metricVec
+	requestDuration       *metricVec
+	requestsInFlight      *metricVec
+	taskDuration          *metricVec
+	tasksInFlight         *metricVec
+	taskItemErrors        *metricVec
+	authorizationFailures *metricVec
+}
+
+/*
+ObserveRequest records finished request
+*/
+func (s *serverMetrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	s.requests.Inc(route, method, strconv.Itoa(status))
+	s.requestDuration.Observe(duration.Seconds(), route, method)
+}
+
+/*
+newMetricsRegistry returns empty registry
+*/
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		metrics: map[string]*metricVec{},
+	}
+}
+
+/*
+metricsRegistry holds metrics by their names
+*/
+type metricsRegistry struct {
+	lock    sync.RWMutex
+	metrics map[string]*metricVec
+}
+
+/*
+Counter registers new counter
+*/
+func (m *metricsRegistry) Counter(name, help string, labels ...string) *metricVec {
+	return m.register(newMetricVec(metricCounter, name, help, nil, labels))
+}
+
+/*
+Gauge registers new gauge
+*/
+func (m *metricsRegistry) Gauge(name, help string, labels ...string) *metricVec {
+	return m.register(newMetricVec(metricGauge, name, help, nil, labels))
+}
+
+/*
+Histogram registers new histogram with given buckets
+*/
+func (m *metricsRegistry) Histogram(name, help string, buckets []float64, labels ...string) *metricVec {
+	return m.register(newMetricVec(metricHistogram, name, help, buckets, labels))
+}
+
+/*
+register adds metric to registry, it panics if metric is already registered
+*/
+func (m *metricsRegistry) register(metric *metricVec) *metricVec {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.metrics[metric.name]; ok {
+		panic(fmt.Sprintf("metric %s already registered", metric.name))
+	}
+	m.metrics[metric.name] = metric
+	return metric
+}
+
+/*
+Write writes all metrics in prometheus text format
+*/
+func (m *metricsRegistry) Write(w io.Writer) error {
+	m.lock.RLock()
+	names := make([]string, 0, len(m.metrics))
+	for name := range m.metrics {
+		names = append(names, name)
+	}
+	m.lock.RUnlock()
+
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		m.lock.RLock()
+		metric := m.metrics[name]
+		m.lock.RUnlock()
+		metric.write(bw)
+	}
+
+	return bw.Flush()
+}
+
+/*
+newMetricVec returns metric with given labels
+*/
+func newMetricVec(kind, name, help string, buckets []float64, labels []string) *metricVec {
+	result := &metricVec{
+		kind:    kind,
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  map[string]*metricSeries{},
+	}
+
+	// metric without labels is exposed from the start
+	if len(labels) == 0 {
+		result.get()
+	}
+	return result
+}
+
+/*
+metricVec is metric partitioned by label values
+*/
+type metricVec struct {
+	kind    string
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	lock   sync.Mutex
+	series map[string]*metricSeries
+}
+
+/*
+metricSeries is single metric with label values
+*/
+type metricSeries struct {
+	values []string
+	value  float64
+
+	// histogram data
+	counts []uint64
+	count  uint64
+}
+
+/*
+get returns series for given label values, caller must hold lock
+*/
+func (m *metricVec) get(values ...string) *metricSeries {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", m.name, len(m.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+	if series, ok := m.series[key]; ok {
+		return series
+	}
+
+	series := &metricSeries{
+		values: values,
+	}
+	if m.kind == metricHistogram {
+		series.counts = make([]uint64, len(m.buckets))
+	}
+	m.series[key] = series
+	return series
+}
+
+/*
+Add adds value to counter or gauge
+*/
+func (m *metricVec) Add(value float64, values ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.get(values...).value += value
+}
+
+/*
+Inc increments counter or gauge
+*/
+func (m *metricVec) Inc(values ...string) {
+	m.Add(1, values...)
+}
+
+/*
+Dec decrements gauge
+*/
+func (m *metricVec) Dec(values ...string) {
+	m.Add(-1, values...)
+}
+
+/*
+Observe adds observation to histogram
+*/
+func (m *metricVec) Observe(value float64, values ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	series := m.get(values...)
+	for i, bound := range m.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.value += value
+}
+
+/*
+write writes metric in prometheus text format
+*/
+func (m *metricVec) write(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeMetricHelp(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		series := m.series[key]
+		if m.kind != metricHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, m.formatLabels(series.values), formatMetricValue(series.value))
+			continue
+		}
+
+		for i, bound := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.formatLabels(series.values, "le", formatMetricValue(bound)), series.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.formatLabels(series.values, "le", "+Inf"), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, m.formatLabels(series.values), formatMetricValue(series.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, m.formatLabels(series.values), series.count)
+	}
+}
+
+/*
+formatLabels returns formatted labels with values, extra is list of additional
+label name, value pairs.
+*/
+func (m *metricVec) formatLabels(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(values)+len(extra)/2)
+	for i, value := range values {
+		parts = append(parts, m.labels[i]+`="`+escapeMetricLabel(value)+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, extra[i]+`="`+escapeMetricLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var (
+	metricHelpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	metricLabelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeMetricHelp(value string) string {
+	return metricHelpReplacer.Replace(value)
+}
+
+func escapeMetricLabel(value string) string {
+	return metricLabelReplacer.Replace(value)
+}
+
+/*
+formatMetricValue formats float value for text format
+*/
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+/*
+MetricsHandler returns handler that serves metrics in prometheus text format.
+*/
+func (s *Server) MetricsHandler(authorizers Authorizers, mc *MetricsConfig) http.HandlerFunc {
+	ec := &EndpointConfig{
+		Path:        mc.Path,
+		Authorizers: mc.Authorizers,
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+
+		state := getRequestState(r.Context())
+		state.Route = mc.Path
+		state.RouteName = ec.RouteName()
+
+		if err := authorizers.Authorize(r, ec); err != nil {
+			NewResponse(http.StatusUnauthorized).Write(w, r, t)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.registry.Write(w)
+	}
+}
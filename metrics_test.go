@@ -0,0 +1,124 @@
+package goexpose
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetrics(t *testing.T) {
+
+	Convey("Test counter", t, func() {
+		registry := newMetricsRegistry()
+		counter := registry.Counter("test_total", "Test counter.", "route", "status")
+		counter.Inc("/a", "200")
+		counter.Inc("/a", "200")
+		counter.Add(3, "/b\"", "500")
+
+		out := &bytes.Buffer{}
+		So(registry.Write(out), ShouldBeNil)
+		So(out.String(), ShouldEqual, `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{route="/a",status="200"} 2
+test_total{route="/b\"",status="500"} 3
+`)
+	})
+
+	Convey("Test gauge without labels", t, func() {
+		registry := newMetricsRegistry()
+		gauge := registry.Gauge("test_in_flight", "Test gauge.")
+
+		out := &bytes.Buffer{}
+		registry.Write(out)
+		So(out.String(), ShouldContainSubstring, "test_in_flight 0\n")
+
+		gauge.Inc()
+		gauge.Inc()
+		gauge.Dec()
+
+		out.Reset()
+		registry.Write(out)
+		So(out.String(), ShouldContainSubstring, "test_in_flight 1\n")
+	})
+
+	Convey("Test histogram", t, func() {
+		registry := newMetricsRegistry()
+		histogram := registry.Histogram("test_seconds", "Test histogram.", []float64{0.1, 1}, "type")
+		histogram.Observe(0.05, "shell")
+		histogram.Observe(0.5, "shell")
+		histogram.Observe(2, "shell")
+
+		out := &bytes.Buffer{}
+		registry.Write(out)
+		So(out.String(), ShouldEqual, `# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{type="shell",le="0.1"} 1
+test_seconds_bucket{type="shell",le="1"} 2
+test_seconds_bucket{type="shell",le="+Inf"} 3
+test_seconds_sum{type="shell"} 2.55
+test_seconds_count{type="shell"} 3
+`)
+	})
+
+	Convey("Test invalid label values", t, func() {
+		registry := newMetricsRegistry()
+		counter := registry.Counter("test_total", "Test counter.", "route")
+		So(func() { counter.Inc() }, ShouldPanic)
+		So(func() { registry.Counter("test_total", "Duplicate.") }, ShouldPanic)
+	})
+
+	Convey("Test task metrics and span when task panics", t, func() {
+		server, err := NewServer(NewConfig())
+		So(err, ShouldBeNil)
+
+		// unstarted tracer only collects recorded spans
+		recorded := &tracer{spans: make(chan *span, 10)}
+		currentTracerLock.Lock()
+		old := currentTracer
+		currentTracer = recorded
+		currentTracerLock.Unlock()
+		defer func() {
+			currentTracerLock.Lock()
+			currentTracer = old
+			currentTracerLock.Unlock()
+		}()
+
+		ec := &EndpointConfig{Path: "/panic"}
+		handler := server.Handle(&panicTask{}, Authorizers{}, ec, &TaskConfig{Type: "panic"})
+
+		request, _ := http.NewRequest("GET", "/panic", nil)
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+
+		out := &bytes.Buffer{}
+		metrics.registry.Write(out)
+		So(out.String(), ShouldContainSubstring, `goexpose_tasks_in_flight{type="panic"} 0`)
+		So(out.String(), ShouldContainSubstring, `goexpose_task_duration_seconds_count{type="panic"} 1`)
+
+		So(len(recorded.spans), ShouldEqual, 1)
+		task := <-recorded.spans
+		So(task.name, ShouldEqual, "task panic")
+		So(task.err, ShouldNotBeEmpty)
+	})
+}
+
+/*
+panicTask panics on every run, it implements ContextTasker so panic is not
+recovered by adapter
+*/
+type panicTask struct {
+	Task
+}
+
+func (p *panicTask) Run(r *http.Request, vars map[string]interface{}) *Response {
+	return p.RunContext(r.Context(), r, vars)
+}
+
+func (p *panicTask) RunContext(ctx context.Context, r *http.Request, vars map[string]interface{}) *Response {
+	panic("task failed")
+}
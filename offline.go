@@ -0,0 +1,62 @@
+package goexpose
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+)
+
+/*
+Offline requests
+
+Endpoints can be run without listener (e.g. from cron jobs). Request goes
+through the same router and handlers as http request, so interpolation data
+(url vars, query params, env, body) are the same.
+*/
+
+/*
+skipAuthorizersKey marks context of offline request that skips authorizers
+*/
+type skipAuthorizersKey struct{}
+
+/*
+authorizersSkipped returns whether authorizers are skipped for request
+*/
+func authorizersSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipAuthorizersKey{}).(bool)
+	return skip
+}
+
+/*
+RunRequest runs request without listener and returns recorded response. When
+skipAuthorizers is true, authorizers are not run (only possible for offline
+requests).
+*/
+func (s *Server) RunRequest(r *http.Request, skipAuthorizers bool) (recorder *httptest.ResponseRecorder, err error) {
+	if err = setupLogging(s.Config.Logging); err != nil {
+		return
+	}
+
+	// export spans, remaining spans are exported on exit
+	if err = setupTracing(s.Config.Tracing); err != nil {
+		return
+	}
+	defer setupTracing(nil)
+
+	if s.Router, err = s.router(); err != nil {
+		return
+	}
+
+	// prepare named connections, they are connected lazily
+	s.connections.Update(s.Config.Connections)
+	defer s.connections.Close()
+
+	ctx := s.ctx
+	if skipAuthorizers {
+		ctx = context.WithValue(ctx, skipAuthorizersKey{}, true)
+	}
+
+	recorder = httptest.NewRecorder()
+	s.instrument(s).ServeHTTP(recorder, r.WithContext(ctx))
+	return
+}
@@ -0,0 +1,34 @@
+package goexpose
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRunRequest(t *testing.T) {
+
+	Convey("Test run request", t, func() {
+		config := NewConfig()
+		config.Authorizers = map[string]*AuthorizerConfig{
+			"basic": {Type: "basic", Config: []byte(`{"username": "u", "password": "p"}`)},
+		}
+		config.Endpoints = []*EndpointConfig{
+			{Path: "/info", Authorizers: []string{"basic"}, Methods: map[string]TaskConfig{"GET": {Type: "info"}}},
+		}
+
+		server, err := NewServer(config)
+		So(err, ShouldBeNil)
+
+		request, _ := http.NewRequest("GET", "/info", nil)
+		response, err := server.RunRequest(request, false)
+		So(err, ShouldBeNil)
+		So(response.Code, ShouldEqual, http.StatusUnauthorized)
+
+		request, _ = http.NewRequest("GET", "/info", nil)
+		response, err = server.RunRequest(request, true)
+		So(err, ShouldBeNil)
+		So(response.Code, ShouldEqual, http.StatusOK)
+	})
+}
@@ -0,0 +1,554 @@
+package goexpose
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+OpenAPI document
+
+OpenAPI 3.1 document is generated from endpoints. Mux variables are path
+parameters (their regexp is pattern), query params are query parameters,
+authorizers are security schemes and result of every task is described by
+result schema registered for task type.
+*/
+
+const (
+	OPENAPI_VERSION = "3.1.0"
+)
+
+/*
+OpenAPIConfig is configuration of openapi endpoint
+*/
+type OpenAPIConfig struct {
+	// path of openapi endpoint, default is /openapi.json
+	Path string `json:"path"`
+
+	// title, version (default is goexpose version) and description of api
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+
+	// urls of servers (e.g. https://api.example.com)
+	Servers []string `json:"servers"`
+
+	// authorizers for openapi endpoint
+	Authorizers []string `json:"authorizers"`
+}
+
+/*
+Validate validates openapi configuration
+*/
+func (o *OpenAPIConfig) Validate() (err error) {
+	o.Path = strings.TrimSpace(o.Path)
+	if o.Path == "" {
+		o.Path = DEFAULT_OPENAPI_PATH
+	}
+	if !strings.HasPrefix(o.Path, "/") {
+		return fmt.Errorf("openapi: path %s must start with /", o.Path)
+	}
+	if o.Title = strings.TrimSpace(o.Title); o.Title == "" {
+		o.Title = DEFAULT_OPENAPI_TITLE
+	}
+	return
+}
+
+/*
+TaskResultSchema returns schema of result of task response. Nil schema means
+that task returns raw (non json) body.
+*/
+type TaskResultSchema func(server *Server, config *TaskConfig) Schema
+
+/*
+SecurityScheme returns openapi security scheme of authorizer, nil means that
+authorizer cannot be described.
+*/
+type SecurityScheme func(config *AuthorizerConfig) Schema
+
+var (
+	taskresultschemas = map[string]TaskResultSchema{}
+	securityschemes   = map[string]SecurityScheme{}
+	openapilock       = &sync.RWMutex{}
+)
+
+/*
+RegisterTaskResultSchema registers schema of result of task type
+*/
+func RegisterTaskResultSchema(id string, schema TaskResultSchema) {
+	openapilock.Lock()
+	defer openapilock.Unlock()
+
+	if _, ok := taskresultschemas[id]; ok {
+		panic(fmt.Sprintf("task result schema %s already registered", id))
+	}
+	taskresultschemas[id] = schema
+}
+
+/*
+RegisterSecurityScheme registers security scheme of authorizer type
+*/
+func RegisterSecurityScheme(id string, scheme SecurityScheme) {
+	openapilock.Lock()
+	defer openapilock.Unlock()
+
+	if _, ok := securityschemes[id]; ok {
+		panic(fmt.Sprintf("security scheme %s already registered", id))
+	}
+	securityschemes[id] = scheme
+}
+
+/*
+StaticSecurityScheme returns security scheme that does not depend on config
+*/
+func StaticSecurityScheme(scheme Schema) SecurityScheme {
+	return func(config *AuthorizerConfig) Schema {
+		return scheme
+	}
+}
+
+/*
+OpenAPI returns openapi document of all endpoints
+*/
+func (s *Server) OpenAPI() (document Schema, err error) {
+	var routes []*route
+	if routes, err = s.routes(); err != nil {
+		return
+	}
+
+	oc := s.Config.OpenAPI
+	if oc == nil {
+		oc = &OpenAPIConfig{}
+		if err = oc.Validate(); err != nil {
+			return
+		}
+	}
+
+	return s.openAPIDocument(routes, oc), nil
+}
+
+/*
+openAPIDocument returns openapi document of given routes
+*/
+func (s *Server) openAPIDocument(routes []*route, oc *OpenAPIConfig) Schema {
+	info := Schema{
+		"title":   oc.Title,
+		"version": oc.Version,
+	}
+	if oc.Version == "" {
+		info["version"] = s.Version
+	}
+	if oc.Description != "" {
+		info["description"] = oc.Description
+	}
+
+	document := Schema{
+		"openapi": OPENAPI_VERSION,
+		"info":    info,
+	}
+
+	if len(oc.Servers) > 0 {
+		servers := []Schema{}
+		for _, url := range oc.Servers {
+			servers = append(servers, Schema{"url": url})
+		}
+		document["servers"] = servers
+	}
+
+	// security schemes of authorizers
+	schemes := Schema{}
+	for name, ac := range s.Config.Authorizers {
+		if ac == nil {
+			continue
+		}
+		openapilock.RLock()
+		scheme, ok := securityschemes[ac.Type]
+		openapilock.RUnlock()
+		if !ok {
+			continue
+		}
+		if value := scheme(ac); value != nil {
+			schemes[name] = value
+		}
+	}
+	if len(schemes) > 0 {
+		document["components"] = Schema{"securitySchemes": schemes}
+	}
+
+	paths := Schema{}
+	operations := map[string]int{}
+	for _, route := range routes {
+		template, parameters := openAPIPath(route.Path)
+
+		item, ok := paths[template].(Schema)
+		if !ok {
+			item = Schema{}
+			paths[template] = item
+		}
+
+		operation := s.openAPIOperation(route, parameters, schemes)
+
+		// operation ids must be unique
+		id := openAPIOperationID(route.Method, template)
+		if operations[id]++; operations[id] > 1 {
+			id = fmt.Sprintf("%s_%d", id, operations[id])
+		}
+		operation["operationId"] = id
+
+		item[strings.ToLower(route.Method)] = operation
+	}
+	document["paths"] = paths
+
+	return document
+}
+
+/*
+openAPIOperation returns operation of route
+*/
+func (s *Server) openAPIOperation(route *route, parameters []Schema, schemes Schema) Schema {
+	ec, tc := route.EndpointConfig, route.TaskConfig
+
+	operation := Schema{
+		"x-goexpose-type": tc.Type,
+	}
+	if tc.Description != "" {
+		operation["summary"] = tc.Description
+	}
+
+	// query params of endpoint are used instead of params of method
+	qp := ec.QueryParams
+	if qp == nil {
+		qp = tc.QueryParams
+	}
+	params := []*QueryParamsConfigParam{}
+	if qp != nil {
+		for _, param := range qp.Params {
+			if param != nil {
+				params = append(params, param)
+			}
+		}
+	}
+	for _, param := range params {
+		schema := Schema{"type": "string"}
+		if param.Regexp != "" {
+			schema["pattern"] = param.Regexp
+		}
+		if param.Default != "" {
+			schema["default"] = param.Default
+		}
+		parameters = append(parameters, Schema{
+			"name":   param.Name,
+			"in":     "query",
+			"schema": schema,
+		})
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	// request body is available to tasks as {{.request.body}}
+	switch route.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		operation["requestBody"] = Schema{
+			"content": Schema{
+				"*/*": Schema{"schema": Schema{"type": "string"}},
+			},
+		}
+	}
+
+	// all authorizers must pass, so they are single security requirement
+	requirement := Schema{}
+	for _, name := range append(append([]string{}, ec.Authorizers...), tc.Authorizers...) {
+		if _, ok := schemes[name]; ok {
+			requirement[name] = []string{}
+		}
+	}
+	if len(requirement) > 0 {
+		operation["security"] = []Schema{requirement}
+	}
+
+	success := Schema{"description": http.StatusText(http.StatusOK)}
+	if result := s.taskResultSchema(tc); result != nil {
+		success["content"] = Schema{
+			"application/json": Schema{"schema": responseSchema(result)},
+		}
+	} else {
+		success["content"] = Schema{
+			"application/octet-stream": Schema{"schema": Schema{"type": "string", "format": "binary"}},
+		}
+	}
+
+	responses := Schema{
+		"200": success,
+		"default": Schema{
+			"description": "Error",
+			"content": Schema{
+				"application/json": Schema{"schema": responseSchema(Schema{})},
+			},
+		},
+	}
+	if len(ec.Authorizers) > 0 || len(tc.Authorizers) > 0 {
+		responses["401"] = Schema{"description": http.StatusText(http.StatusUnauthorized)}
+	}
+	operation["responses"] = responses
+
+	return operation
+}
+
+/*
+taskResultSchema returns result schema of task, unknown tasks can return anything
+*/
+func (s *Server) taskResultSchema(tc *TaskConfig) Schema {
+	openapilock.RLock()
+	schema, ok := taskresultschemas[tc.Type]
+	openapilock.RUnlock()
+
+	if !ok {
+		return Schema{}
+	}
+	return schema(s, tc)
+}
+
+/*
+OpenAPIHandler returns handler that serves openapi document
+*/
+func (s *Server) OpenAPIHandler(authorizers Authorizers, oc *OpenAPIConfig, document Schema) http.HandlerFunc {
+	ec := &EndpointConfig{
+		Path:        oc.Path,
+		Authorizers: oc.Authorizers,
+	}
+
+	body, encodeErr := json.Marshal(document)
+	if s.Config.PrettyJson {
+		body, encodeErr = json.MarshalIndent(document, "", "    ")
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+
+		state := getRequestState(r.Context())
+		state.Route = oc.Path
+		state.RouteName = ec.RouteName()
+
+		if err := authorizers.Authorize(r, ec); err != nil {
+			NewResponse(http.StatusUnauthorized).Write(w, r, t)
+			return
+		}
+
+		if encodeErr != nil {
+			NewResponse(http.StatusInternalServerError).Error(encodeErr).Write(w, r, t)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+/*
+openAPIPath returns openapi path template and path parameters of mux path.
+Variables with regexp ({id:[0-9]+}) have regexp as pattern of parameter.
+*/
+func openAPIPath(path string) (template string, parameters []Schema) {
+	result := &strings.Builder{}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '{' {
+			result.WriteByte(path[i])
+			continue
+		}
+
+		// find closing brace, regexp can contain braces too
+		end, depth := -1, 0
+		for j := i; j < len(path) && end == -1; j++ {
+			switch path[j] {
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					end = j
+				}
+			}
+		}
+		if end == -1 {
+			result.WriteString(path[i:])
+			break
+		}
+
+		name, pattern := path[i+1:end], ""
+		if k := strings.Index(name, ":"); k != -1 {
+			name, pattern = name[:k], name[k+1:]
+		}
+		name = strings.TrimSpace(name)
+
+		schema := Schema{"type": "string"}
+		if pattern != "" {
+			schema["pattern"] = "^" + pattern + "$"
+		}
+
+		result.WriteString("{" + name + "}")
+		parameters = append(parameters, Schema{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+		i = end
+	}
+	return result.String(), parameters
+}
+
+/*
+openAPIOperationID returns operation id from method and path (e.g. get_users_id)
+*/
+func openAPIOperationID(method, template string) string {
+	parts := []string{strings.ToLower(method)}
+	for _, part := range strings.FieldsFunc(template, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "_")
+}
+
+/*
+responseSchema returns schema of response with given result
+*/
+func responseSchema(result Schema) Schema {
+	return Schema{
+		"type": "object",
+		"properties": Schema{
+			"status":    Schema{"type": "integer"},
+			"message":   Schema{"type": "string"},
+			"result":    result,
+			"error":     Schema{},
+			"params":    Schema{"type": "object"},
+			"timed_out": Schema{"type": "array", "items": Schema{"type": "string"}},
+		},
+		"required": []string{"status", "message"},
+	}
+}
+
+/*
+itemsSchema returns schema of result of task with multiple items (queries,
+commands, urls), with single_result only one item is returned.
+*/
+func itemsSchema(tc *TaskConfig, item Schema) Schema {
+	config := struct {
+		SingleResult *int `json:"single_result"`
+	}{}
+	if json.Unmarshal(tc.Config, &config) == nil && config.SingleResult != nil {
+		return item
+	}
+	return Schema{"type": "array", "items": item}
+}
+
+/*
+objectSchema returns schema of object with given properties
+*/
+func objectSchema(properties Schema) Schema {
+	return Schema{"type": "object", "properties": properties}
+}
+
+func shellResultSchema(server *Server, tc *TaskConfig) Schema {
+	return itemsSchema(tc, objectSchema(Schema{
+		"command": Schema{"type": "string"},
+		"format":  Schema{"type": "string"},
+		"result":  Schema{},
+		"error":   Schema{},
+	}))
+}
+
+func httpResultSchema(server *Server, tc *TaskConfig) Schema {
+	return itemsSchema(tc, objectSchema(Schema{
+		"format":  Schema{"type": "string"},
+		"headers": Schema{"type": "object", "additionalProperties": Schema{"type": "array", "items": Schema{"type": "string"}}},
+		"result":  Schema{},
+		"error":   Schema{},
+	}))
+}
+
+func queryResultSchema(server *Server, tc *TaskConfig) Schema {
+	return itemsSchema(tc, objectSchema(Schema{
+		"query":      Schema{"type": "string"},
+		"args":       Schema{"type": "array"},
+		"result":     Schema{"type": "array", "items": Schema{"type": "object"}},
+		"error":      Schema{},
+		"error_code": Schema{},
+	}))
+}
+
+func redisResultSchema(server *Server, tc *TaskConfig) Schema {
+	return itemsSchema(tc, objectSchema(Schema{
+		"command": Schema{"type": "string"},
+		"args":    Schema{"type": "array"},
+		"result":  Schema{},
+		"error":   Schema{},
+	}))
+}
+
+func infoResultSchema(server *Server, tc *TaskConfig) Schema {
+	return objectSchema(Schema{
+		"version": Schema{"type": "string"},
+		"reload":  Schema{"type": "object"},
+		"endpoints": Schema{"type": "array", "items": objectSchema(Schema{
+			"path":        Schema{"type": "string"},
+			"method":      Schema{"type": "string"},
+			"type":        Schema{"type": "string"},
+			"description": Schema{"type": "string"},
+			"authorizers": Schema{"type": "array", "items": Schema{"type": "string"}},
+		})},
+	})
+}
+
+func filesystemResultSchema(server *Server, tc *TaskConfig) Schema {
+	config := NewFilesystemConfig()
+	if json.Unmarshal(tc.Config, config) == nil && strings.TrimSpace(strings.ToLower(config.Output)) == "raw" {
+		return nil
+	}
+	return Schema{
+		"oneOf": []Schema{
+			{"type": "string", "contentEncoding": "base64"},
+			{"type": "array", "items": objectSchema(Schema{
+				"result": Schema{"type": "string"},
+				"is_dir": Schema{"type": "boolean"},
+			})},
+		},
+	}
+}
+
+/*
+multiResultSchema returns responses of subtasks, with single_result response of
+one subtask.
+*/
+func multiResultSchema(server *Server, tc *TaskConfig) Schema {
+	config := &MultiTaskConfig{}
+	if json.Unmarshal(tc.Config, config) != nil {
+		return Schema{}
+	}
+
+	responses := []Schema{}
+	for _, subtask := range config.Tasks {
+		if subtask == nil || server.Config.extendTask(subtask) != nil {
+			responses = append(responses, responseSchema(Schema{}))
+			continue
+		}
+
+		result := server.taskResultSchema(subtask)
+		if result == nil {
+			result = Schema{}
+		}
+		responses = append(responses, responseSchema(result))
+	}
+
+	if config.SingleResult != nil {
+		if index := *config.SingleResult; index >= 0 && index < len(responses) {
+			return responses[index]
+		}
+		return Schema{}
+	}
+	return Schema{"type": "array", "prefixItems": responses, "items": false}
+}
@@ -0,0 +1,76 @@
+package goexpose
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOpenAPI(t *testing.T) {
+
+	Convey("Test path parameters", t, func() {
+		template, parameters := openAPIPath("/users/{id:[0-9]{1,5}}/{name}")
+		So(template, ShouldEqual, "/users/{id}/{name}")
+		So(len(parameters), ShouldEqual, 2)
+		So(parameters[0]["schema"].(Schema)["pattern"], ShouldEqual, "^[0-9]{1,5}$")
+		So(parameters[1]["name"], ShouldEqual, "name")
+
+		So(openAPIOperationID("GET", template), ShouldEqual, "get_users_id_name")
+	})
+
+	Convey("Test document", t, func() {
+		config := NewConfig()
+		config.Authorizers = map[string]*AuthorizerConfig{
+			"basic": {Type: "basic", Config: json.RawMessage(`{"username": "u", "password": "p"}`)},
+		}
+		config.Endpoints = []*EndpointConfig{{
+			Path:        "/echo/{id}",
+			Authorizers: []string{"basic"},
+			Methods: map[string]TaskConfig{
+				"GET": {Type: "shell", Description: "echo", Config: json.RawMessage(`{"single_result": 0, "commands": [{"command": "echo"}]}`)},
+			},
+		}}
+
+		server, err := NewServer(config)
+		So(err, ShouldBeNil)
+
+		document, err := server.OpenAPI()
+		So(err, ShouldBeNil)
+		So(document["openapi"], ShouldEqual, OPENAPI_VERSION)
+
+		operation := document["paths"].(Schema)["/echo/{id}"].(Schema)["get"].(Schema)
+		So(operation["summary"], ShouldEqual, "echo")
+		So(operation["security"], ShouldResemble, []Schema{{"basic": []string{}}})
+
+		// single result is not list
+		schema := operation["responses"].(Schema)["200"].(Schema)["content"].(Schema)["application/json"].(Schema)["schema"].(Schema)
+		So(schema["properties"].(Schema)["result"].(Schema)["type"], ShouldEqual, "object")
+	})
+
+	Convey("Test query params of endpoint are used instead of params of method", t, func() {
+		config := NewConfig()
+		config.Endpoints = []*EndpointConfig{{
+			Path: "/info",
+			QueryParams: &QueryParams{
+				Params: []*QueryParamsConfigParam{{Name: "endpoint"}},
+			},
+			Methods: map[string]TaskConfig{
+				"GET": {Type: "info", QueryParams: &QueryParams{
+					Params: []*QueryParamsConfigParam{{Name: "method"}},
+				}},
+			},
+		}}
+
+		server, err := NewServer(config)
+		So(err, ShouldBeNil)
+
+		document, err := server.OpenAPI()
+		So(err, ShouldBeNil)
+
+		operation := document["paths"].(Schema)["/info"].(Schema)["get"].(Schema)
+		parameters := operation["parameters"].([]Schema)
+		So(len(parameters), ShouldEqual, 1)
+		So(parameters[0]["name"], ShouldEqual, "endpoint")
+	})
+}
@@ -0,0 +1,224 @@
+package goexpose
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	ErrReloadNoFilename = errors.New("configuration was not loaded from file, cannot reload")
+)
+
+/*
+reloadStats holds information about configuration reloads
+*/
+type reloadStats struct {
+	Count      int        `json:"count"`
+	Failed     int        `json:"failed"`
+	LastError  string     `json:"last_error,omitempty"`
+	LastReload *time.Time `json:"last_reload,omitempty"`
+}
+
+/*
+Reload reads configuration file again, builds new router and swaps it.
+If anything fails old configuration and router are kept.
+*/
+func (s *Server) Reload() (err error) {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+
+	current := s.GetConfig()
+
+	var config *Config
+	if current.Filename == "" {
+		err = ErrReloadNoFilename
+	} else if config, err = NewConfigFromFilename(current.Filename, current.Format, current.options...); err == nil {
+		err = s.swap(config)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.reloads.LastReload = &now
+	if err != nil {
+		s.reloads.Failed++
+		s.reloads.LastError = err.Error()
+		logger().Errorf("Reload of configuration %s failed, keeping old configuration: %v", current.Filename, err)
+		return
+	}
+
+	s.reloads.Count++
+	s.reloads.LastError = ""
+	logger().Infof("Configuration %s reloaded", current.Filename)
+	return
+}
+
+/*
+ReloadStats returns information about configuration reloads
+*/
+func (s *Server) ReloadStats() reloadStats {
+	// server that built reloaded configuration reports stats of running server
+	if s.origin != nil {
+		return s.origin.ReloadStats()
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.reloads
+}
+
+/*
+swap sets new configuration and router. Router is built from new configuration
+and only if that succeeds it's swapped. Requests are served by old router while
+new one is built, lock is held only for the swap itself.
+*/
+func (s *Server) swap(config *Config) (err error) {
+	old := s.GetConfig()
+	next := s.withConfig(config)
+
+	var router *mux.Router
+	if router, err = next.router(); err != nil {
+		return
+	}
+
+	if err = next.checkLint(); err != nil {
+		return
+	}
+
+	// logger and tracer are created first and set together with router, so
+	// failed reload doesn't leave part of new configuration active
+	var (
+		l Logger
+		t *tracer
+	)
+	loggingChanged := !reflect.DeepEqual(old.Logging, config.Logging)
+	tracingChanged := !reflect.DeepEqual(old.Tracing, config.Tracing)
+
+	if loggingChanged {
+		if l, err = loggerFromConfig(config.Logging); err != nil {
+			return
+		}
+	}
+
+	if tracingChanged {
+		if t, err = tracerFromConfig(config.Tracing); err != nil {
+			if closer, ok := l.(io.Closer); ok {
+				closer.Close()
+			}
+			return
+		}
+	}
+
+	if loggingChanged {
+		SetLogger(l)
+	}
+	if tracingChanged {
+		setTracer(t)
+	}
+
+	s.lock.Lock()
+	s.Config = config
+	s.Router = router
+	s.lock.Unlock()
+
+	// close changed and removed connections
+	s.connections.Update(config.Connections)
+
+	// some settings cannot be changed without restart
+	if !reflect.DeepEqual(old.GetListeners(), config.GetListeners()) {
+		logger().Warningf("Changes in listeners (host, port, ssl) require restart")
+	}
+	if !reflect.DeepEqual(old.Reload, config.Reload) {
+		logger().Warningf("Changes in reload configuration require restart")
+	}
+
+	return
+}
+
+/*
+withConfig returns server used to build router and tasks of given configuration.
+It shares running tasks, connections and base context with s.
+*/
+func (s *Server) withConfig(config *Config) *Server {
+	return &Server{
+		Config:      config,
+		Version:     s.Version,
+		inflight:    s.inflight,
+		connections: s.connections,
+		ctx:         s.ctx,
+		cancel:      s.cancel,
+		stop:        s.stop,
+		origin:      s,
+	}
+}
+
+/*
+watchReload reloads configuration on SIGHUP and optionally when configuration
+file changes. Returned function stops watching.
+*/
+func (s *Server) watchReload() (stop func()) {
+	config := s.GetConfig()
+
+	var watcher *fileWatcher
+	if config.Reload != nil && config.Reload.Watch && config.Filename != "" {
+		interval := time.Duration(config.Reload.Interval)
+		if interval <= 0 {
+			interval = DEFAULT_RELOAD_INTERVAL
+		}
+
+		logger().Debugf("Watching configuration %v for changes every %v", config.watchedFiles(), interval)
+		watcher = newFileWatcher(interval, func(changed []string) {
+			logger().Infof("Configuration %v changed, reloading", changed)
+			s.Reload()
+
+			// included files could change
+			watcher.SetFiles(s.GetConfig().watchedFiles()...)
+		}, config.watchedFiles()...)
+		watcher.Start()
+	}
+
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				logger().Infof("Received SIGHUP, reloading configuration")
+				s.Reload()
+				if watcher != nil {
+					watcher.SetFiles(s.GetConfig().watchedFiles()...)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+		if watcher != nil {
+			watcher.Stop()
+		}
+	}
+}
+
+/*
+watchedFiles returns files and directories that are watched for changes
+*/
+func (c *Config) watchedFiles() []string {
+	if len(c.Files) == 0 {
+		return []string{c.Filename}
+	}
+	return c.Files
+}
@@ -3,20 +3,20 @@ package goexpose
 /*
 Response module
 
-small helper to make writing json responses easier with along with logging of requests.
+small helper to make writing json responses easier.
 
 Usage:
 
 	in all examples r is *http.Request and w is http.ResponseWriter
 	NewResponse(http.StatusNotFound).Write(w, r)
 
-	writes following json response along with log message:
+	writes following json response:
 	{
 		"status": 404,
 		"message": "Not found"
 	}
 
-	Following example adds also time to response log, and also result.
+	Following example adds also result.
 	Result will be marshalled json
 
 	t := time.Now()
@@ -70,8 +70,6 @@ import (
 	"time"
 
 	"encoding/base64"
-
-	"github.com/golang/glog"
 )
 
 /*
@@ -200,7 +198,8 @@ func (r *Response) HasValue(key string) bool {
 }
 
 /*
-Writes response to response writer and logs request
+Writes response to response writer. Requests are logged by server (see logging),
+start is kept for backwards compatibility.
 */
 func (r *Response) Write(w http.ResponseWriter, req *http.Request, start ...time.Time) (err error) {
 	var (
@@ -221,22 +220,6 @@ func (r *Response) Write(w http.ResponseWriter, req *http.Request, start ...time
 		w.Write(body)
 	}
 
-	var (
-		format string
-		args   []interface{}
-	)
-
-	// log request
-	if len(start) > 0 {
-		format = "%s %s %d %v"
-		args = []interface{}{req.Method, req.URL.Path, r.status, time.Now().Sub(start[0])}
-	} else {
-		format = "%s %s %d"
-		args = []interface{}{req.Method, req.URL.Path, r.status}
-	}
-
-	glog.V(1).Infof(format, args...)
-
 	return
 }
 
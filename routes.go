@@ -0,0 +1,302 @@
+package goexpose
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+Routes listing
+
+Routes are registered to mux router in order of endpoints, first route that
+matches request handles it. Routes that can never be matched (shadowed by
+earlier route) or that share some paths with earlier route (overlap) are
+reported as conflicts.
+*/
+
+/*
+RouteInfo is information about registered route
+*/
+type RouteInfo struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Type        string   `json:"type"`
+	Authorizers []string `json:"authorizers"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+
+	// where endpoint is defined (file and json pointer)
+	Source string `json:"source,omitempty"`
+}
+
+/*
+RouteConflict is route that is shadowed by or overlaps with earlier route
+*/
+type RouteConflict struct {
+	Route *RouteInfo `json:"route"`
+	By    *RouteInfo `json:"by"`
+
+	// route is never matched
+	Shadowed bool `json:"shadowed"`
+}
+
+func (r *RouteConflict) String() string {
+	relation := "overlaps with"
+	if r.Shadowed {
+		relation = "is shadowed by"
+	}
+	return fmt.Sprintf("%s %s %s %s %s", r.Route.Method, r.Route.Path, relation, r.By.Method, r.By.Path)
+}
+
+/*
+Routes returns all routes in order they are registered to router
+*/
+func (s *Server) Routes() (result []*RouteInfo, err error) {
+	var routes []*route
+	if routes, err = s.routes(); err != nil {
+		return
+	}
+
+	result = make([]*RouteInfo, 0, len(routes))
+	for _, route := range routes {
+		ec, tc := route.EndpointConfig, route.TaskConfig
+
+		authorizers := []string{}
+		added := map[string]bool{}
+		for _, name := range append(append([]string{}, ec.Authorizers...), tc.Authorizers...) {
+			if !added[name] {
+				added[name] = true
+				authorizers = append(authorizers, name)
+			}
+		}
+
+		info := &RouteInfo{
+			Method:      route.Method,
+			Path:        route.Path,
+			Type:        tc.Type,
+			Authorizers: authorizers,
+			Name:        ec.RouteName(),
+			Description: tc.Description,
+		}
+		if ec.source.File != "" {
+			info.Source = ec.source.File + "#" + ec.source.Pointer
+		} else {
+			info.Source = ec.source.Pointer
+		}
+		result = append(result, info)
+	}
+	return
+}
+
+/*
+RouteConflicts returns routes that are shadowed by or overlap with earlier
+routes with same method. Patterns of variables are compared only when they are
+same or when one of them matches any segment, so some overlaps are not found.
+*/
+func RouteConflicts(routes []*RouteInfo) (result []*RouteConflict) {
+	patterns := make([]*routePattern, len(routes))
+	for i, route := range routes {
+		patterns[i] = newRoutePattern(route.Path)
+	}
+
+	for i, route := range routes {
+		for j := 0; j < i; j++ {
+			if !strings.EqualFold(routes[j].Method, route.Method) {
+				continue
+			}
+			if relation := patterns[j].compare(patterns[i]); relation != routeDisjoint {
+				result = append(result, &RouteConflict{
+					Route:    route,
+					By:       routes[j],
+					Shadowed: relation == routeCovers,
+				})
+				break
+			}
+		}
+	}
+	return
+}
+
+const (
+	// routes don't share any path (or it cannot be decided)
+	routeDisjoint = iota
+
+	// all paths of second route match first route
+	routeCovers
+
+	// routes share some paths
+	routeOverlaps
+)
+
+/*
+routePattern is mux path template split to segments
+*/
+type routePattern struct {
+	path     string
+	literal  bool
+	regexp   *regexp.Regexp
+	segments []*routeSegment
+
+	// variable can match "/", so segments cannot be compared
+	multiSegment bool
+}
+
+/*
+routeSegment is part of path between slashes
+*/
+type routeSegment struct {
+	literal string
+	pattern string
+	regexp  *regexp.Regexp
+
+	// segment is single variable that matches any segment
+	any bool
+}
+
+/*
+newRoutePattern parses mux path template
+*/
+func newRoutePattern(path string) *routePattern {
+	result := &routePattern{path: path, literal: true}
+
+	full := &strings.Builder{}
+	segment := &routeSegment{}
+	variables := 0
+
+	addLiteral := func(text string) {
+		parts := strings.Split(text, "/")
+		for i, part := range parts {
+			if i > 0 {
+				result.addSegment(segment, variables)
+				segment, variables = &routeSegment{}, 0
+			}
+			segment.literal += part
+			segment.pattern += regexp.QuoteMeta(part)
+		}
+		full.WriteString(regexp.QuoteMeta(text))
+	}
+
+	for i := 0; i < len(path); i++ {
+		if path[i] != '{' {
+			end := strings.IndexByte(path[i:], '{')
+			if end == -1 {
+				end = len(path) - i
+			}
+			addLiteral(path[i : i+end])
+			i += end - 1
+			continue
+		}
+
+		// find closing brace, regexp can contain braces too
+		end, depth := -1, 0
+		for j := i; j < len(path) && end == -1; j++ {
+			switch path[j] {
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					end = j
+				}
+			}
+		}
+		if end == -1 {
+			addLiteral(path[i:])
+			break
+		}
+
+		pattern := "[^/]+"
+		if k := strings.Index(path[i+1:end], ":"); k != -1 {
+			pattern = path[i+1+k+1 : end]
+		}
+		if re, err := regexp.Compile("^(?:" + pattern + ")$"); err == nil && re.MatchString("a/b") {
+			result.multiSegment = true
+		}
+
+		result.literal = false
+		segment.pattern += "(?:" + pattern + ")"
+		variables++
+		full.WriteString("(?:" + pattern + ")")
+		i = end
+	}
+	result.addSegment(segment, variables)
+
+	result.regexp, _ = regexp.Compile("^" + full.String() + "$")
+	return result
+}
+
+/*
+addSegment adds parsed segment to pattern
+*/
+func (p *routePattern) addSegment(segment *routeSegment, variables int) {
+	if variables > 0 {
+		segment.literal = ""
+		segment.any = variables == 1 && (segment.pattern == "(?:[^/]+)" || segment.pattern == "(?:[^/]*)")
+		segment.regexp, _ = regexp.Compile("^" + segment.pattern + "$")
+	}
+	p.segments = append(p.segments, segment)
+}
+
+/*
+compare returns relation of pattern (earlier route) to other pattern (later route)
+*/
+func (p *routePattern) compare(other *routePattern) int {
+	if p.regexp == nil || other.regexp == nil {
+		return routeDisjoint
+	}
+
+	// literal paths can be matched by whole regexp
+	if other.literal && p.regexp.MatchString(other.path) {
+		return routeCovers
+	}
+	if p.literal {
+		if other.regexp.MatchString(p.path) {
+			return routeOverlaps
+		}
+		return routeDisjoint
+	}
+
+	if p.multiSegment || other.multiSegment || len(p.segments) != len(other.segments) {
+		return routeDisjoint
+	}
+
+	covers := true
+	for i, segment := range p.segments {
+		switch relation := segment.compare(other.segments[i]); relation {
+		case routeDisjoint:
+			return routeDisjoint
+		case routeOverlaps:
+			covers = false
+		}
+	}
+
+	if covers {
+		return routeCovers
+	}
+	return routeOverlaps
+}
+
+/*
+compare returns relation of segment to other segment
+*/
+func (s *routeSegment) compare(other *routeSegment) int {
+	switch {
+	case s.regexp == nil && other.regexp == nil:
+		if s.literal == other.literal {
+			return routeCovers
+		}
+	case other.regexp == nil:
+		if s.regexp.MatchString(other.literal) {
+			return routeCovers
+		}
+	case s.regexp == nil:
+		if other.regexp.MatchString(s.literal) {
+			return routeOverlaps
+		}
+	case s.pattern == other.pattern || s.any:
+		return routeCovers
+	case other.any:
+		return routeOverlaps
+	}
+	return routeDisjoint
+}
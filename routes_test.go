@@ -0,0 +1,63 @@
+package goexpose
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRoutes(t *testing.T) {
+
+	Convey("Test route conflicts", t, func() {
+		routes := func(paths ...string) []*RouteInfo {
+			result := []*RouteInfo{}
+			for _, path := range paths {
+				result = append(result, &RouteInfo{Method: "GET", Path: path})
+			}
+			return result
+		}
+
+		conflicts := RouteConflicts(routes("/users/{id}", "/users/me"))
+		So(len(conflicts), ShouldEqual, 1)
+		So(conflicts[0].Shadowed, ShouldBeTrue)
+		So(conflicts[0].String(), ShouldEqual, "GET /users/me is shadowed by GET /users/{id}")
+
+		conflicts = RouteConflicts(routes("/users/me", "/users/{id}"))
+		So(len(conflicts), ShouldEqual, 1)
+		So(conflicts[0].Shadowed, ShouldBeFalse)
+
+		So(RouteConflicts(routes("/users/{id:[0-9]+}", "/users/me")), ShouldBeEmpty)
+		So(RouteConflicts(routes("/users/{id}", "/users/{id}/detail")), ShouldBeEmpty)
+		So(len(RouteConflicts(routes("/users/{id}/{name}", "/users/{pk:[0-9]+}/detail"))), ShouldEqual, 1)
+
+		different := routes("/users/{id}", "/users/me")
+		different[1].Method = "POST"
+		So(RouteConflicts(different), ShouldBeEmpty)
+	})
+
+	Convey("Test routes", t, func() {
+		config := NewConfig()
+		config.Endpoints = []*EndpointConfig{{
+			Path:        "/info",
+			Authorizers: []string{"a"},
+			Methods: map[string]TaskConfig{
+				"GET": {Type: "info", Authorizers: []string{"b", "a"}},
+			},
+		}}
+		config.Authorizers = map[string]*AuthorizerConfig{
+			"a": {Type: "basic", Config: json.RawMessage(`{"username": "u", "password": "p"}`)},
+			"b": {Type: "basic", Config: json.RawMessage(`{"username": "u", "password": "p"}`)},
+		}
+
+		server, err := NewServer(config)
+		So(err, ShouldBeNil)
+
+		routes, err := server.Routes()
+		So(err, ShouldBeNil)
+		So(len(routes), ShouldEqual, 1)
+		So(routes[0].Type, ShouldEqual, "info")
+		So(routes[0].Authorizers, ShouldResemble, []string{"a", "b"})
+		So(routes[0].Name, ShouldEqual, config.Endpoints[0].RouteName())
+	})
+}
@@ -0,0 +1,234 @@
+package goexpose
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+JSON Schema of configuration
+
+Task, authorizer and connection factories can declare schema of their config
+block. Schema of whole configuration uses discriminated unions on "type", so
+editors can autocomplete and validate config of every registered type.
+*/
+
+const (
+	SCHEMA_DRAFT = "http://json-schema.org/draft-07/schema#"
+)
+
+/*
+Schema is JSON Schema object
+*/
+type Schema map[string]interface{}
+
+var (
+	taskschemas           = map[string]Schema{}
+	authorizerschemas     = map[string]Schema{}
+	connectionschemas     = map[string]Schema{}
+	configschemaslock     = &sync.RWMutex{}
+	durationType          = reflect.TypeOf(Duration(0))
+	rawMessageType        = reflect.TypeOf(json.RawMessage{})
+	taskConfigType        = reflect.TypeOf(TaskConfig{})
+	authorizerConfigType  = reflect.TypeOf(AuthorizerConfig{})
+	connectionConfigType  = reflect.TypeOf(ConnectionConfig{})
+	schemaDefinitionNames = map[reflect.Type]string{
+		taskConfigType:       "task",
+		authorizerConfigType: "authorizer",
+		connectionConfigType: "connection",
+	}
+)
+
+/*
+RegisterTaskSchema registers schema of config of task factory. Config is either
+Schema or value of config struct (e.g. HttpTaskConfig{}), schema of struct is
+generated from its json fields.
+*/
+func RegisterTaskSchema(id string, config interface{}) {
+	registerSchema(taskschemas, "task", id, config)
+}
+
+/*
+RegisterAuthorizerSchema registers schema of config of authorizer factory
+*/
+func RegisterAuthorizerSchema(id string, config interface{}) {
+	registerSchema(authorizerschemas, "authorizer", id, config)
+}
+
+/*
+RegisterConnectionSchema registers schema of config of connection factory
+*/
+func RegisterConnectionSchema(id string, config interface{}) {
+	registerSchema(connectionschemas, "connection", id, config)
+}
+
+/*
+registerSchema adds schema to registry, it panics if schema is already registered
+*/
+func registerSchema(registry map[string]Schema, kind, id string, config interface{}) {
+	configschemaslock.Lock()
+	defer configschemaslock.Unlock()
+
+	if _, ok := registry[id]; ok {
+		panic(fmt.Sprintf("%s schema %s already registered", kind, id))
+	}
+
+	if schema, ok := config.(Schema); ok {
+		registry[id] = schema
+	} else {
+		registry[id] = schemaOf(reflect.TypeOf(config))
+	}
+}
+
+/*
+ConfigSchema returns JSON Schema of whole configuration. Types of tasks,
+authorizers and connections are registered factories, their config is validated
+by registered schema.
+*/
+func ConfigSchema() Schema {
+	schema := schemaOf(reflect.TypeOf(Config{}))
+	schema["$schema"] = SCHEMA_DRAFT
+	schema["title"] = "goexpose configuration"
+
+	// reference to this schema for editors
+	properties := schema["properties"].(Schema)
+	properties["$schema"] = Schema{"type": "string"}
+
+	taskregistrylock.RLock()
+	tasktypes := make([]string, 0, len(taskregistry))
+	for id := range taskregistry {
+		tasktypes = append(tasktypes, id)
+	}
+	taskregistrylock.RUnlock()
+
+	authorizerslock.RLock()
+	authorizertypes := make([]string, 0, len(authorizers))
+	for id := range authorizers {
+		authorizertypes = append(authorizertypes, id)
+	}
+	authorizerslock.RUnlock()
+
+	connectionfactorieslock.RLock()
+	connectiontypes := make([]string, 0, len(connectionfactories))
+	for id := range connectionfactories {
+		connectiontypes = append(connectiontypes, id)
+	}
+	connectionfactorieslock.RUnlock()
+
+	configschemaslock.RLock()
+	defer configschemaslock.RUnlock()
+
+	schema["definitions"] = Schema{
+		"task":       unionSchema(taskConfigType, tasktypes, taskschemas, false),
+		"authorizer": unionSchema(authorizerConfigType, authorizertypes, authorizerschemas, true),
+		"connection": unionSchema(connectionConfigType, connectiontypes, connectionschemas, true),
+	}
+	return schema
+}
+
+/*
+unionSchema returns schema of struct with type and config fields. Type is one
+of registered types and config is validated by schema of given type. Type of
+task is not required, it can be set by template or endpoint.
+*/
+func unionSchema(t reflect.Type, types []string, schemas map[string]Schema, required bool) Schema {
+	sort.Strings(types)
+
+	schema := structSchema(t)
+	properties := schema["properties"].(Schema)
+	properties["type"] = Schema{"type": "string", "enum": types}
+	if required {
+		schema["required"] = []string{"type"}
+	}
+
+	conditions := []Schema{}
+	for _, id := range types {
+		config, ok := schemas[id]
+		if !ok {
+			continue
+		}
+		conditions = append(conditions, Schema{
+			"if": Schema{
+				"properties": Schema{"type": Schema{"const": id}},
+				"required":   []string{"type"},
+			},
+			"then": Schema{
+				"properties": Schema{"config": config},
+			},
+		})
+	}
+	if len(conditions) > 0 {
+		schema["allOf"] = conditions
+	}
+	return schema
+}
+
+/*
+schemaOf returns schema of given type. Task, authorizer and connection configs
+are references to definitions.
+*/
+func schemaOf(t reflect.Type) Schema {
+	if t == nil {
+		return Schema{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if name, ok := schemaDefinitionNames[t]; ok {
+		return Schema{"$ref": "#/definitions/" + name}
+	}
+
+	switch t {
+	case durationType:
+		return Schema{
+			"type":        []string{"string", "number"},
+			"description": "duration (e.g. \"30s\") or number of seconds",
+		}
+	case rawMessageType:
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return structSchema(t)
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	}
+	return Schema{}
+}
+
+/*
+structSchema returns schema of struct, unknown properties are not allowed (same
+as in strict decoding of configuration).
+*/
+func structSchema(t reflect.Type) Schema {
+	properties := Schema{}
+	for _, field := range jsonFields(t) {
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type)
+	}
+	return Schema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
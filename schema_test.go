@@ -0,0 +1,34 @@
+package goexpose
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchema(t *testing.T) {
+
+	Convey("Test struct schema", t, func() {
+		schema := schemaOf(reflect.TypeOf(&MultiTaskConfig{}))
+		So(schema["additionalProperties"], ShouldEqual, false)
+
+		properties := schema["properties"].(Schema)
+		So(properties["single_result"], ShouldResemble, Schema{"type": "integer"})
+		So(properties["tasks"], ShouldResemble, Schema{"type": "array", "items": Schema{"$ref": "#/definitions/task"}})
+		_, ok := properties["singleResultIndex"]
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Test register schema", t, func() {
+		RegisterTaskSchema("schema_example", Schema{"type": "object"})
+		So(func() { RegisterTaskSchema("schema_example", Schema{}) }, ShouldPanic)
+	})
+
+	Convey("Test config schema", t, func() {
+		schema := ConfigSchema()
+		task := schema["definitions"].(Schema)["task"].(Schema)
+		So(task["properties"].(Schema)["type"].(Schema)["enum"], ShouldContain, "http")
+		So(len(task["allOf"].([]Schema)), ShouldBeGreaterThan, 0)
+	})
+}
@@ -0,0 +1,169 @@
+package goexpose
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Encrypted secrets
+
+Values in configuration can be encrypted with AES-256-GCM so configuration can
+be committed to repository. Encrypted value has form "enc:<base64>" where
+base64 is nonce followed by ciphertext. Key file contains base64 encoded 32 byte
+key and is passed on command line. Values are decrypted when configuration is
+loaded.
+*/
+
+const (
+	// prefix of encrypted values
+	SecretPrefix = "enc:"
+
+	// size of secrets key in bytes (AES-256)
+	secretsKeySize = 32
+)
+
+var (
+	ErrSecretsKeyMissing = errors.New("encrypted value found, but secrets key was not given")
+	ErrSecretsKeyInvalid = errors.New("secrets key must be base64 encoded 32 bytes")
+	ErrSecretInvalid     = errors.New("invalid encrypted value")
+)
+
+/*
+GenerateSecretsKey returns new random key in key file format
+*/
+func GenerateSecretsKey() (result string, err error) {
+	key := make([]byte, secretsKeySize)
+	if _, err = rand.Read(key); err != nil {
+		return
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+/*
+ReadSecretsKey reads key from key file
+*/
+func ReadSecretsKey(filename string) (key []byte, err error) {
+	var body []byte
+	if body, err = ioutil.ReadFile(filename); err != nil {
+		return
+	}
+
+	if key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(body))); err != nil || len(key) != secretsKeySize {
+		return nil, fmt.Errorf("%s: %v", filename, ErrSecretsKeyInvalid)
+	}
+	return
+}
+
+/*
+EncryptSecret encrypts value with key, result can be used in configuration
+*/
+func EncryptSecret(key []byte, value string) (result string, err error) {
+	var aead cipher.AEAD
+	if aead, err = newSecretsCipher(key); err != nil {
+		return
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), nil)
+	return SecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+/*
+DecryptSecret decrypts value encrypted by EncryptSecret
+*/
+func DecryptSecret(key []byte, value string) (result string, err error) {
+	if key == nil {
+		return "", ErrSecretsKeyMissing
+	}
+
+	var aead cipher.AEAD
+	if aead, err = newSecretsCipher(key); err != nil {
+		return
+	}
+
+	var sealed []byte
+	if sealed, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(value, SecretPrefix)); err != nil {
+		return "", ErrSecretInvalid
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrSecretInvalid
+	}
+
+	var plain []byte
+	if plain, err = aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil); err != nil {
+		return "", fmt.Errorf("cannot decrypt value, wrong secrets key?")
+	}
+	return string(plain), nil
+}
+
+/*
+isSecret returns whether value is encrypted
+*/
+func isSecret(value string) bool {
+	return strings.HasPrefix(value, SecretPrefix)
+}
+
+/*
+findSecrets adds encrypted values of json document to secrets by their decrypted
+value, so they can be replaced back when configuration is dumped. Values that
+cannot be decrypted are skipped (they are reported when variables are resolved).
+*/
+func findSecrets(document []byte, key []byte, secrets map[string]string) {
+	var value interface{}
+	if json.Unmarshal(document, &value) != nil {
+		return
+	}
+
+	mapStrings(value, func(s string) string {
+		if isSecret(s) {
+			if plain, err := DecryptSecret(key, s); err == nil {
+				secrets[plain] = s
+			}
+		}
+		return s
+	})
+}
+
+func newSecretsCipher(key []byte) (result cipher.AEAD, err error) {
+	if len(key) != secretsKeySize {
+		return nil, ErrSecretsKeyInvalid
+	}
+
+	var block cipher.Block
+	if block, err = aes.NewCipher(key); err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+/*
+WithSecretsKeyFile returns option that decrypts encrypted values in configuration
+with key from given key file. Key file is read again on every reload.
+*/
+func WithSecretsKeyFile(filename string) ConfigOption {
+	// working directory can change before reload
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+
+	return func(l *configLoader) (err error) {
+		if l.secretsKey, err = ReadSecretsKey(filename); err != nil {
+			return
+		}
+		l.files = append(l.files, filename)
+		return
+	}
+}
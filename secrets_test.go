@@ -0,0 +1,41 @@
+package goexpose
+
+import (
+	"encoding/base64"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSecrets(t *testing.T) {
+
+	Convey("Test encrypt and decrypt", t, func() {
+		encoded, err := GenerateSecretsKey()
+		So(err, ShouldBeNil)
+		key, _ := base64.StdEncoding.DecodeString(encoded)
+
+		value, err := EncryptSecret(key, "password")
+		So(err, ShouldBeNil)
+		So(value, ShouldStartWith, SecretPrefix)
+
+		plain, err := DecryptSecret(key, value)
+		So(err, ShouldBeNil)
+		So(plain, ShouldEqual, "password")
+
+		other := make([]byte, secretsKeySize)
+		_, err = DecryptSecret(other, value)
+		So(err, ShouldNotBeNil)
+
+		_, err = DecryptSecret(nil, value)
+		So(err, ShouldEqual, ErrSecretsKeyMissing)
+	})
+
+	Convey("Test encrypted values in document", t, func() {
+		key := make([]byte, secretsKeySize)
+		value, _ := EncryptSecret(key, "${NOT_RESOLVED}")
+
+		result, err := substituteVariables([]byte(`{"password": "`+value+`"}`), "", key)
+		So(err, ShouldBeNil)
+		So(string(result), ShouldEqual, `{"password":"${NOT_RESOLVED}"}`)
+	})
+}
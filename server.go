@@ -1,8 +1,14 @@
 package goexpose
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"os/signal"
+	"syscall"
 
 	"time"
 
@@ -10,9 +16,10 @@ import (
 	"runtime/debug"
 
 	"os"
+	"sort"
 	"strings"
+	"sync"
 
-	"github.com/golang/glog"
 	"github.com/gorilla/mux"
 )
 
@@ -31,10 +38,19 @@ Returns new server instance
 */
 func NewServer(config *Config) (server *Server, err error) {
 	server = &Server{
-		Config:  config,
-		Version: VERSION,
+		Config:      config,
+		Version:     VERSION,
+		inflight:    newInflight(),
+		stop:        make(chan struct{}),
+		connections: newConnections(),
 	}
 
+	// base context for all requests, cancelled when drain timeout expires
+	server.ctx, server.cancel = context.WithCancel(context.Background())
+
+	// configuration can be built without loading it from file, so tasks extend
+	// templates here too (already extended tasks don't change)
+	err = config.applyTaskTemplates()
 	return
 }
 
@@ -51,6 +67,48 @@ type Server struct {
 
 	// Router
 	Router *mux.Router
+
+	// running tasks
+	inflight *inflight
+
+	// named connections
+	connections *Connections
+
+	// base context for requests
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// closed when Shutdown is called
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// lock guards Config, Router and reloads
+	lock       sync.RWMutex
+	reloadLock sync.Mutex
+	reloads    reloadStats
+
+	// running server when this server only builds reloaded configuration
+	origin *Server
+}
+
+/*
+GetConfig returns current configuration
+*/
+func (s *Server) GetConfig() *Config {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.Config
+}
+
+/*
+ServeHTTP dispatches request to current router, so router can be swapped on reload.
+*/
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.RLock()
+	router := s.Router
+	s.lock.RUnlock()
+
+	router.ServeHTTP(w, r)
 }
 
 /*
@@ -58,29 +116,126 @@ Runs http server
 */
 func (s *Server) Run() (err error) {
 
-	glog.V(2).Infof(logo, s.Version)
+	// logging is configured first so everything is logged to configured output
+	if err = setupLogging(s.Config.Logging); err != nil {
+		return
+	}
+
+	// export spans, remaining spans are exported on exit
+	if err = setupTracing(s.Config.Tracing); err != nil {
+		return
+	}
+	defer setupTracing(nil)
+
+	logger().Debugf(logo, s.Version)
 
 	if s.Router, err = s.router(); err != nil {
 		return
 	}
 
-	// construct listen from host and port
-	listen := fmt.Sprintf("%s:%d", s.Config.Host, s.Config.Port)
+	// dangerous configuration is logged or refused by lint severity
+	if err = s.checkLint(); err != nil {
+		return
+	}
+
+	config := s.GetConfig()
 
-	// ssl version
-	if s.Config.SSL != nil {
-		glog.Infof("Start listen on https://%s", listen)
-		if err = http.ListenAndServeTLS(listen, s.Config.SSL.Cert, s.Config.SSL.Key, s.Router); err != nil {
+	// prepare named connections, they are connected lazily
+	s.connections.Update(config.Connections)
+	defer s.connections.Close()
+
+	listeners := config.GetListeners()
+	servers := make([]*http.Server, 0, len(listeners))
+	errs := make(chan error, len(listeners))
+
+	// start all listeners
+	for _, lc := range listeners {
+		var (
+			server *http.Server
+			ln     net.Listener
+		)
+		if server, ln, err = s.listen(lc); err != nil {
+			shutdownServers(context.Background(), servers)
 			return
 		}
-	} else {
-		glog.Infof("Start listen on http://%s", listen)
-		if err = http.ListenAndServe(listen, s.Router); err != nil {
-			return
+		servers = append(servers, server)
+
+		go func(lc *ListenerConfig) {
+			errs <- s.serve(lc, server, ln)
+		}(lc)
+	}
+
+	// reload configuration on SIGHUP or file change
+	stopReload := s.watchReload()
+	defer stopReload()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err = <-errs:
+		shutdownServers(context.Background(), servers)
+		return
+	case sig := <-signals:
+		logger().Infof("Received signal %v, shutting down", sig)
+	case <-s.stop:
+		logger().Infof("Shutting down")
+	}
+
+	return s.shutdown(servers)
+}
+
+/*
+Shutdown stops running server. Server stops accepting new connections and
+waits for running tasks (see Config.DrainTimeout), then the rest is cancelled.
+*/
+func (s *Server) Shutdown() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+/*
+shutdown gracefully stops http servers.
+First it stops accepting connections and waits drain timeout for running tasks,
+after that all remaining tasks are cancelled.
+*/
+func (s *Server) shutdown(servers []*http.Server) (err error) {
+	drain := time.Duration(s.GetConfig().DrainTimeout)
+
+	if count := s.inflight.Len(); count > 0 {
+		logger().Infof("Waiting %v for %d running task(s) to finish", drain, count)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+
+	if err = shutdownServers(ctx, servers); err == nil {
+		logger().Infof("Server stopped")
+		return
+	}
+
+	// drain timeout expired, cancel remaining tasks
+	for _, task := range s.inflight.List() {
+		logger().Warningf("Interrupting task %s %s (endpoint: %s, type: %s), running for %v",
+			task.Method, task.Path, task.Endpoint, task.Type, time.Since(task.Started))
+	}
+	s.cancel()
+
+	// give cancelled tasks short time to write their responses
+	ctx, cancel = context.WithTimeout(context.Background(), DEFAULT_CANCEL_TIMEOUT)
+	defer cancel()
+
+	if err = shutdownServers(ctx, servers); err != nil {
+		logger().Warningf("Closing %d connection(s) that did not finish after cancel", s.inflight.Len())
+		for _, server := range servers {
+			server.Close()
 		}
 	}
 
-	return
+	logger().Infof("Server stopped")
+	return nil
 }
 
 /*
@@ -98,13 +253,41 @@ func (s *Server) router(ignored ...string) (router *mux.Router, err error) {
 
 	for _, route := range routes {
 		// Log registered route
-		glog.V(2).Infof("Register route for task: %s path: %s method: %v",
+		logger().Debugf("Register route for task: %s path: %s method: %v",
 			route.TaskConfig.Type, route.Path, route.Method)
 
 		// register route to router
 		router.HandleFunc(route.Path, s.Handle(route.Task, route.Authorizers, route.EndpointConfig, route.TaskConfig)).Methods(route.Method).Name(route.EndpointConfig.RouteName())
 	}
 
+	// register built-in endpoints
+	if s.Config.Metrics == nil && s.Config.Health == nil && s.Config.OpenAPI == nil {
+		return
+	}
+
+	var authorizers Authorizers
+	if authorizers, err = GetAuthorizers(s.Config); err != nil {
+		return
+	}
+
+	if mc := s.Config.Metrics; mc != nil {
+		logger().Debugf("Register metrics endpoint path: %s", mc.Path)
+		router.HandleFunc(mc.Path, s.MetricsHandler(authorizers, mc)).Methods("GET").Name((&EndpointConfig{Path: mc.Path}).RouteName())
+	}
+
+	if hc := s.Config.Health; hc != nil {
+		probes := s.probes(routes, authorizers)
+
+		logger().Debugf("Register health endpoints paths: %s, %s (%d probes)", hc.LivenessPath, hc.ReadinessPath, len(probes))
+		router.HandleFunc(hc.LivenessPath, s.LivenessHandler(authorizers, hc)).Methods("GET").Name((&EndpointConfig{Path: hc.LivenessPath}).RouteName())
+		router.HandleFunc(hc.ReadinessPath, s.ReadinessHandler(authorizers, hc, probes)).Methods("GET").Name((&EndpointConfig{Path: hc.ReadinessPath}).RouteName())
+	}
+
+	if oc := s.Config.OpenAPI; oc != nil {
+		logger().Debugf("Register openapi endpoint path: %s", oc.Path)
+		router.HandleFunc(oc.Path, s.OpenAPIHandler(authorizers, oc, s.openAPIDocument(routes, oc))).Methods("GET").Name((&EndpointConfig{Path: oc.Path}).RouteName())
+	}
+
 	return
 }
 
@@ -121,51 +304,116 @@ type route struct {
 }
 
 /*
-Returns prepared routes
+Returns prepared routes, on error routes that are valid are returned with error
 */
 func (s *Server) routes(ignored ...string) (routes []*route, err error) {
 	var (
 		authorizers Authorizers
-		factory     TaskFactory
-		ok          bool
-		tasks       []Tasker
+		errs        ConfigErrors
 	)
 
 	routes = []*route{}
+
+	// validate listeners
+	errs.Add(s.Config.ValidateListeners())
+
+	// validate named connections, factories don't connect so they are run to validate config
+	names := make([]string, 0, len(s.Config.Connections))
+	for name := range s.Config.Connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		source := configSource{File: s.Config.settingFiles["connections"], Pointer: "/connections/" + escapePointer(name)}
+		cc := s.Config.Connections[name]
+		if err = cc.Validate(); err != nil {
+			errs.Add(source.Wrap(fmt.Errorf("connection %s: %v", name, err), ""))
+			continue
+		}
+
+		factory, _ := getConnectionFactory(cc.Type)
+
+		var connection Connection
+		if connection, err = factory(cc); err != nil {
+			errs.Add(source.Wrap(err, "/config"))
+			continue
+		}
+		connection.Close()
+	}
+
+	// validate logging
+	if s.Config.Logging != nil {
+		errs.Add(s.Config.settingSource("logging").Wrap(s.Config.Logging.Validate(), ""))
+	}
+
+	// validate tracing
+	if s.Config.Tracing != nil {
+		errs.Add(s.Config.settingSource("tracing").Wrap(s.Config.Tracing.Validate(), ""))
+	}
+
+	// validate built-in endpoints
+	if s.Config.Metrics != nil {
+		errs.Add(s.Config.settingSource("metrics").Wrap(s.Config.Metrics.Validate(), ""))
+	}
+	if s.Config.Health != nil {
+		errs.Add(s.Config.settingSource("health").Wrap(s.Config.Health.Validate(), ""))
+	}
+	if s.Config.OpenAPI != nil {
+		errs.Add(s.Config.settingSource("openapi").Wrap(s.Config.OpenAPI.Validate(), ""))
+	}
+	if s.Config.Lint != nil {
+		errs.Add(s.Config.settingSource("lint").Wrap(s.Config.Lint.Validate(), ""))
+	}
+	if len(errs) == 0 {
+		errs.Add(s.Config.validateBuiltinPaths())
+	}
+
 	// Get all authorizers
 	if authorizers, err = GetAuthorizers(s.Config); err != nil {
-		return
+		errs.Add(err)
 	}
 
 Outer:
-	for _, econfig := range s.Config.Endpoints {
+	for i, econfig := range s.Config.Endpoints {
+		source := s.Config.endpointSource(i)
 
 		if err = econfig.Validate(); err != nil {
-			return
+			errs.Add(source.WrapEndpoint(err, "", econfig.Path, ""))
+			continue
 		}
 
-		for method, taskconf := range econfig.Methods {
+		methods := make([]string, 0, len(econfig.Methods))
+		for method := range econfig.Methods {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
 
-			// ignored task
-			for _, it := range ignored {
-				if taskconf.Type == it {
-					continue Outer
-				}
+		for _, method := range methods {
+			taskconf := econfig.Methods[method]
+			pointer := "/methods/" + escapePointer(method)
+
+			// ignored task (also inside multi task)
+			if s.Config.taskUsesType(&taskconf, ignored...) {
+				continue Outer
 			}
 
 			// validate task config
 			if err = taskconf.Validate(); err != nil {
-				return
+				errs.Add(source.WrapEndpoint(err, pointer, econfig.Path, method))
+				continue
 			}
 
-			if factory, ok = getTaskFactory(taskconf.Type); !ok {
-				err = fmt.Errorf("task %s doesn't exist", taskconf.Type)
-				return
+			factory, ok := getTaskFactory(taskconf.Type)
+			if !ok {
+				errs.Add(source.WrapEndpoint(fmt.Errorf("task %s doesn't exist", taskconf.Type), pointer+"/type", econfig.Path, method))
+				continue
 			}
 
+			var tasks []Tasker
 			if tasks, err = factory(s, &taskconf, econfig); err != nil {
-				err = fmt.Errorf("task %s returned error: %s", taskconf.Type, err)
-				return
+				errs.Add(source.WrapEndpoint(err, pointer+"/config", econfig.Path, method))
+				continue
 			}
 
 			for _, task := range tasks {
@@ -186,9 +434,55 @@ Outer:
 		}
 
 	}
+
+	err = errs.Err()
 	return
 }
 
+/*
+taskUsesType returns whether task is one of given types, subtasks of multi task
+are checked too (info task in multi task would otherwise build routes forever).
+Tasks are checked with task templates they extend.
+*/
+func (c *Config) taskUsesType(taskconf *TaskConfig, types ...string) bool {
+	if c.extendedTaskType(taskconf, types...) {
+		return true
+	}
+
+	extended := *taskconf
+	if c.extendTask(&extended) != nil || extended.Type != "multi" {
+		return false
+	}
+
+	// multi task does not support embedded multi tasks, so only subtasks are checked
+	mtc := &MultiTaskConfig{}
+	if json.Unmarshal(extended.Config, mtc) == nil {
+		for _, subtask := range mtc.Tasks {
+			if subtask != nil && c.extendedTaskType(subtask, types...) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+extendedTaskType returns whether task with its task template is one of given types
+*/
+func (c *Config) extendedTaskType(taskconf *TaskConfig, types ...string) bool {
+	extended := *taskconf
+	if c.extendTask(&extended) != nil {
+		extended = *taskconf
+	}
+
+	for _, t := range types {
+		if extended.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
 /*
 Handle func
 */
@@ -196,12 +490,49 @@ func (s *Server) Handle(task Tasker, authorizers Authorizers, ec *EndpointConfig
 
 	env := s.GetEnv()
 
+	// configuration valid for this handler (can be swapped on reload)
+	config := s.Config
+
+	// tasks without context support are adapted
+	runner := NewContextTasker(task)
+
+	// route path used in metrics and logs
+	path := ec.Path + task.Path()
+	name := ec.RouteName()
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		t := time.Now()
+
+		// information about request for logging
+		state := getRequestState(r.Context())
+		state.Route = path
+		state.RouteName = name
+		state.Method = r.Method
+		state.TaskType = tc.Type
+
+		// record request metrics when response is written
+		sw := &statusWriter{ResponseWriter: w}
+		w = sw
+
+		metrics.requestsInFlight.Inc()
+		defer func() {
+			metrics.requestsInFlight.Dec()
+			metrics.ObserveRequest(path, r.Method, sw.Status(), time.Since(t))
+		}()
+
+		// track running task, so we know what was interrupted on shutdown
+		id := s.inflight.Add(&inflightTask{
+			Method:   r.Method,
+			Path:     r.URL.Path,
+			Endpoint: ec.Path,
+			Type:     tc.Type,
+		})
+		defer s.inflight.Remove(id)
+
 		defer func() {
 			if e := recover(); e != nil {
-				debug.PrintStack()
-				NewResponse(http.StatusInternalServerError).Pretty(s.Config.PrettyJson).Error(e).Write(w, r, t)
+				requestLogger(r.Context()).Errorf("Task %s panicked: %v\n%s", tc.Type, e, debug.Stack())
+				NewResponse(http.StatusInternalServerError).Pretty(config.PrettyJson).Error(e).Write(w, r, t)
 			}
 		}()
 
@@ -211,11 +542,12 @@ func (s *Server) Handle(task Tasker, authorizers Authorizers, ec *EndpointConfig
 			return
 		}
 
-		// read request body
+		// read request body, body is restored so tasks can read it again
 		var body = ""
 		if r.Body != nil {
-			if b, err := ioutil.ReadAll(r.Body); err != nil {
+			if b, err := ioutil.ReadAll(r.Body); err == nil {
 				body = string(b)
+				r.Body = ioutil.NopCloser(bytes.NewReader(b))
 			}
 		}
 
@@ -223,25 +555,63 @@ func (s *Server) Handle(task Tasker, authorizers Authorizers, ec *EndpointConfig
 		 prepare data for task
 		    mux vars are under "url"
 		    cleaned query params are under "query"
+		    claims verified by authorizers are under "claims"
 		*/
+		claims := state.Claims
+		if claims == nil {
+			claims = map[string]interface{}{}
+		}
 		params := map[string]interface{}{
-			"url":   mux.Vars(r),
-			"query": s.GetQueryParams(r, ec),
+			"url":    mux.Vars(r),
+			"query":  s.GetQueryParams(r, ec),
+			"claims": claims,
 			"request": map[string]interface{}{
-				"method": r.Method,
-				"body":   body,
+				"method":      r.Method,
+				"body":        body,
+				"id":          state.RequestID,
+				"traceparent": state.Trace.String(),
 			},
 		}
 
 		// reload environment every request?
-		if s.Config.ReloadEnv {
+		if config.ReloadEnv {
 			params["env"] = s.GetEnv()
 		} else {
 			params["env"] = env
 		}
 
-		// prepare response
-		response := task.Run(r, params)
+		// task timeout, endpoint timeout is used as default
+		timeout := tc.Timeout
+		if timeout == 0 {
+			timeout = ec.Timeout
+		}
+
+		ctx, cancel := taskContext(r.Context(), timeout)
+		defer cancel()
+
+		ctx = withRequestState(ctx, state)
+
+		// task span, task items and downstream requests are its children
+		ctx, span := startSpan(ctx, "task "+tc.Type, spanKindInternal)
+		span.SetAttribute("goexpose.task.type", tc.Type)
+
+		// prepare response, metrics and span are finished also when task panics
+		response := func() (response *Response) {
+			metrics.tasksInFlight.Inc(tc.Type)
+			defer metrics.tasksInFlight.Dec(tc.Type)
+
+			defer func() {
+				metrics.taskDuration.Observe(time.Since(span.start).Seconds(), tc.Type)
+				if response == nil {
+					span.SetError(http.StatusText(http.StatusInternalServerError))
+				} else if response.GetStatus() >= http.StatusInternalServerError {
+					span.SetError(http.StatusText(response.GetStatus()))
+				}
+				span.End()
+			}()
+
+			return runner.RunContext(ctx, r, params)
+		}()
 
 		// should i add params
 		if ec.QueryParams != nil {
@@ -261,6 +631,107 @@ func (s *Server) Handle(task Tasker, authorizers Authorizers, ec *EndpointConfig
 	}
 }
 
+/*
+instrument wraps handler of listener. Every request gets request id and trace
+context, request span is recorded and request is logged.
+*/
+func (s *Server) instrument(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+
+		// request state is filled by handlers and authorizers
+		state := &requestState{
+			RequestID: r.Header.Get(requestIDHeader),
+		}
+		if !validRequestID(state.RequestID) {
+			state.RequestID = newRequestID()
+		}
+
+		ctx := withRequestState(r.Context(), state)
+
+		// continue trace from client
+		if parent, ok := parseTraceparent(r.Header.Get(traceparentHeader)); ok {
+			ctx = withTraceContext(ctx, parent)
+		}
+
+		ctx, span := startSpan(ctx, r.Method, spanKindServer)
+		state.Trace = span.trace
+		r = r.WithContext(ctx)
+
+		w.Header().Set(requestIDHeader, state.RequestID)
+		w.Header().Set(traceparentHeader, state.Trace.String())
+
+		sw := &statusWriter{ResponseWriter: w}
+		handler.ServeHTTP(sw, r)
+
+		// request span is named by matched route
+		if state.Route != "" {
+			span.name = r.Method + " " + state.Route
+			span.SetAttribute("http.route", state.Route)
+		}
+		span.SetAttribute("http.method", r.Method).
+			SetAttribute("http.target", r.URL.RequestURI()).
+			SetAttribute("http.status_code", sw.Status()).
+			SetAttribute("goexpose.request_id", state.RequestID)
+		if sw.Status() >= http.StatusInternalServerError {
+			span.SetError(http.StatusText(sw.Status()))
+		}
+		span.End()
+
+		logger().Access(&AccessRecord{
+			Time:       t,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Route:      state.Route,
+			RouteName:  state.RouteName,
+			TaskType:   state.TaskType,
+			User:       state.User,
+			RemoteAddr: r.RemoteAddr,
+			Status:     sw.Status(),
+			Bytes:      sw.bytes,
+			RequestID:  state.RequestID,
+			TraceID:    state.Trace.TraceIDString(),
+			SpanID:     state.Trace.SpanIDString(),
+			Duration:   time.Since(t).Seconds(),
+		})
+	})
+}
+
+/*
+statusWriter remembers status and size of response
+*/
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+/*
+Status returns written status, if nothing was written yet, 200 is returned
+*/
+func (s *statusWriter) Status() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
 /*
 Handler for not found
 */
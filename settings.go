@@ -1,5 +1,66 @@
 package goexpose
 
+import "time"
+
 const (
 	VERSION = "1.0.0"
+
+	// default time to wait for running tasks on shutdown
+	DEFAULT_DRAIN_TIMEOUT = 30 * time.Second
+
+	// time given to cancelled tasks to finish after drain timeout expired
+	DEFAULT_CANCEL_TIMEOUT = 5 * time.Second
+
+	// default interval to check config file for changes
+	DEFAULT_RELOAD_INTERVAL = 5 * time.Second
+
+	// default interval to check certificate files for changes
+	DEFAULT_CERT_RELOAD_INTERVAL = time.Minute
+
+	// default maximum of idle connections in redis pool
+	DEFAULT_REDIS_MAX_IDLE = 3
+
+	// default path of metrics endpoint
+	DEFAULT_METRICS_PATH = "/metrics"
+
+	// default size of log file in megabytes when it's rotated
+	DEFAULT_LOG_MAX_SIZE = 100
+
+	// default number of rotated log files
+	DEFAULT_LOG_MAX_BACKUPS = 5
+
+	// default paths of health endpoints
+	DEFAULT_LIVENESS_PATH  = "/healthz"
+	DEFAULT_READINESS_PATH = "/readyz"
+
+	// default timeout of health check
+	DEFAULT_HEALTH_TIMEOUT = 5 * time.Second
+
+	// default interval of exporting spans
+	DEFAULT_TRACING_FLUSH_INTERVAL = 5 * time.Second
+
+	// maximum number of spans in single export
+	DEFAULT_TRACING_BATCH_SIZE = 512
+
+	// maximum number of spans waiting for export
+	DEFAULT_TRACING_QUEUE_SIZE = 4096
+
+	// default interval to check jwks file of jwt authorizer for changes
+	DEFAULT_JWKS_RELOAD_INTERVAL = time.Minute
+
+	// default header and interval to check keys file of apikey authorizer for changes
+	DEFAULT_APIKEY_HEADER          = "X-API-Key"
+	DEFAULT_APIKEY_RELOAD_INTERVAL = 10 * time.Second
+
+	// defaults of hmac authorizer
+	DEFAULT_HMAC_HASH             = "sha256"
+	DEFAULT_HMAC_SIGNATURE_HEADER = "X-Signature"
+	DEFAULT_HMAC_TIMESTAMP_HEADER = "X-Timestamp"
+	DEFAULT_HMAC_MAX_AGE          = 5 * time.Minute
+	DEFAULT_HMAC_NONCE_CACHE_SIZE = 100000
+	DEFAULT_HMAC_MAX_BODY_SIZE    = 10 << 20
+
+	// default path and title of openapi document
+	DEFAULT_OPENAPI_PATH  = "/openapi.json"
+	DEFAULT_OPENAPI_TITLE = "goexpose"
 )
\ No newline at end of file
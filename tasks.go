@@ -1,8 +1,8 @@
 package goexpose
 
 import (
+	"context"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"net/http"
 
@@ -11,10 +11,13 @@ import (
 
 	"io"
 	"io/ioutil"
+	"net"
 	"strings"
 
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/garyburd/redigo/redis"
 	"github.com/go-sql-driver/mysql"
@@ -34,6 +37,27 @@ func init() {
 	RegisterTaskFactory("shell", ShellTaskFactory)
 	RegisterTaskFactory("multi", MultiTaskFactory)
 	RegisterTaskFactory("filesystem", FilesystemFactory)
+
+	// register schemas of task configs
+	RegisterTaskSchema("cassandra", CassandraTaskConfig{})
+	RegisterTaskSchema("http", HttpTaskConfig{})
+	RegisterTaskSchema("mysql", MySQLTaskConfig{})
+	RegisterTaskSchema("postgres", PostgresTaskConfig{})
+	RegisterTaskSchema("redis", RedisTaskConfig{})
+	RegisterTaskSchema("shell", ShellTaskConfig{})
+	RegisterTaskSchema("multi", MultiTaskConfig{})
+	RegisterTaskSchema("filesystem", FilesystemConfig{})
+
+	// register schemas of task results (openapi)
+	RegisterTaskResultSchema("cassandra", queryResultSchema)
+	RegisterTaskResultSchema("http", httpResultSchema)
+	RegisterTaskResultSchema("info", infoResultSchema)
+	RegisterTaskResultSchema("mysql", queryResultSchema)
+	RegisterTaskResultSchema("postgres", queryResultSchema)
+	RegisterTaskResultSchema("redis", redisResultSchema)
+	RegisterTaskResultSchema("shell", shellResultSchema)
+	RegisterTaskResultSchema("multi", multiResultSchema)
+	RegisterTaskResultSchema("filesystem", filesystemResultSchema)
 }
 
 /*
@@ -97,7 +121,7 @@ Factory for ShellTask
 */
 func ShellTaskFactory(server *Server, taskconfig *TaskConfig, ec *EndpointConfig) (tasks []Tasker, err error) {
 	config := NewShellTaskConfig()
-	if err = json.Unmarshal(taskconfig.Config, config); err != nil {
+	if err = decodeConfig(taskconfig.Config, config); err != nil {
 		return
 	}
 
@@ -123,16 +147,23 @@ type ShellTask struct {
 
 /*
 Run method for shell task
-Run all commands and return results
 */
 func (s *ShellTask) Run(r *http.Request, data map[string]interface{}) (response *Response) {
+	return s.RunContext(r.Context(), r, data)
+}
+
+/*
+RunContext runs all commands and return results
+*/
+func (s *ShellTask) RunContext(ctx context.Context, r *http.Request, data map[string]interface{}) (response *Response) {
 
 	results := []*Response{}
+	items := newTaskItems("shell")
 
 	response = NewResponse(http.StatusOK)
 
 	// run all commands
-	for _, command := range s.Config.Commands {
+	for i, command := range s.Config.Commands {
 
 		// strip status data from response
 		cmdresp := NewResponse(http.StatusOK).StripStatusData()
@@ -156,7 +187,11 @@ func (s *ShellTask) Run(r *http.Request, data map[string]interface{}) (response
 		}
 
 		// run command
-		cmd = exec.Command(s.Config.Shell, "-c", finalCommand)
+		// command is killed when request is cancelled or timed out
+		cmd = exec.CommandContext(ctx, s.Config.Shell, "-c", finalCommand)
+
+		// don't wait for output of orphaned subprocesses after shell is killed
+		cmd.WaitDelay = time.Second
 
 		// change directory if needed
 		if command.Chdir != "" {
@@ -168,6 +203,14 @@ func (s *ShellTask) Run(r *http.Request, data map[string]interface{}) (response
 			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
 		}
 
+		// request id and traceparent, without configured env vars environment is inherited
+		if env := traceEnv(ctx); len(env) > 0 {
+			if cmd.Env == nil {
+				cmd.Env = os.Environ()
+			}
+			cmd.Env = append(cmd.Env, env...)
+		}
+
 		// get output
 		if out, err := cmd.Output(); err != nil {
 			cmdresp.Error(err)
@@ -183,6 +226,7 @@ func (s *ShellTask) Run(r *http.Request, data map[string]interface{}) (response
 		}
 
 	Append:
+		items.Check(ctx, cmdresp, "commands[%d]", i)
 		results = append(results, cmdresp.StripStatusData())
 	}
 
@@ -193,7 +237,7 @@ func (s *ShellTask) Run(r *http.Request, data map[string]interface{}) (response
 		response.Result(results)
 	}
 
-	return
+	return items.Apply(response)
 }
 
 /*
@@ -201,13 +245,12 @@ Factory for InfoTask task
 */
 func InfoTaskFactory(server *Server, taskconfig *TaskConfig, ec *EndpointConfig) (tasks []Tasker, err error) {
 
-	// get information about all routes
-	var routes []*route
-	if routes, err = server.routes("info"); err != nil {
-		return
-	}
+	// get information about all routes, errors are reported when server
+	// builds its own routes
+	routes, _ := server.routes("info")
 
 	tasks = []Tasker{&InfoTask{
+		server:  server,
 		version: server.Version,
 		routes:  routes,
 	}}
@@ -220,6 +263,9 @@ InfoTask - information about goexpose server
 type InfoTask struct {
 	Task
 
+	// server instance to get runtime information
+	server *Server
+
 	// store version
 	version string
 
@@ -252,6 +298,7 @@ func (i *InfoTask) Run(r *http.Request, data map[string]interface{}) (response *
 	return NewResponse(http.StatusOK).Result(map[string]interface{}{
 		"version":   i.version,
 		"endpoints": endpoints,
+		"reload":    i.server.ReloadStats(),
 	})
 }
 
@@ -318,7 +365,7 @@ func HttpTaskFactory(server *Server, tc *TaskConfig, ec *EndpointConfig) (tasks
 	// default config
 	config := &HttpTaskConfig{}
 
-	if err = json.Unmarshal(tc.Config, config); err != nil {
+	if err = decodeConfig(tc.Config, config); err != nil {
 		return
 	}
 
@@ -346,17 +393,25 @@ type HttpTask struct {
 
 /*
 Run method is called on request
-@TODO: please refactor me!
 */
 func (h *HttpTask) Run(r *http.Request, data map[string]interface{}) (response *Response) {
+	return h.RunContext(r.Context(), r, data)
+}
+
+/*
+RunContext makes requests to all urls
+@TODO: please refactor me!
+*/
+func (h *HttpTask) RunContext(ctx context.Context, r *http.Request, data map[string]interface{}) (response *Response) {
 
 	results := []*Response{}
+	items := newTaskItems("http")
 
 	response = NewResponse(http.StatusOK)
 
 	var err error
 
-	for _, url := range h.config.URLs {
+	for i, url := range h.config.URLs {
 
 		ir := NewResponse(http.StatusOK).StripStatusData()
 
@@ -386,11 +441,14 @@ func (h *HttpTask) Run(r *http.Request, data map[string]interface{}) (response *
 			goto Append
 		}
 
-		if req, err = http.NewRequest(method, b, body); err != nil {
+		if req, err = http.NewRequestWithContext(ctx, method, b, body); err != nil {
 			ir.Error(err)
 			goto Append
 		}
 
+		// propagate request id and trace context
+		setTraceHeaders(ctx, req.Header)
+
 		if resp, err = client.Do(req); err != nil {
 			ir.Error(err)
 			goto Append
@@ -429,6 +487,7 @@ func (h *HttpTask) Run(r *http.Request, data map[string]interface{}) (response *
 		}
 
 	Append:
+		items.Check(ctx, ir, "urls[%d]", i)
 		results = append(results, ir)
 	}
 
@@ -439,7 +498,7 @@ func (h *HttpTask) Run(r *http.Request, data map[string]interface{}) (response *
 		response.Result(results)
 	}
 
-	return
+	return items.Apply(response)
 }
 
 /*
@@ -450,14 +509,24 @@ run queries on postgres database
 
 func PostgresTaskFactory(server *Server, tc *TaskConfig, ec *EndpointConfig) (tasks []Tasker, err error) {
 	config := &PostgresTaskConfig{}
-	if err = json.Unmarshal(tc.Config, config); err != nil {
+	if err = decodeConfig(tc.Config, config); err != nil {
 		return
 	}
 	if err = config.Validate(); err != nil {
 		return
 	}
+	for _, query := range config.Queries {
+		if query.Connection == "" {
+			continue
+		}
+		if err = server.Config.CheckConnection(query.Connection, "postgres"); err != nil {
+			return
+		}
+	}
+
 	tasks = []Tasker{&PostgresTask{
-		config: config,
+		config:      config,
+		connections: server.connections,
 	}}
 
 	return
@@ -474,6 +543,11 @@ func (p *PostgresTaskConfig) Validate() (err error) {
 	if len(p.Queries) == 0 {
 		return errors.New("please provide at least one queryS")
 	}
+	for _, query := range p.Queries {
+		if err = query.Validate(); err != nil {
+			return
+		}
+	}
 	if p.SingleResult != nil {
 		p.singleResultIndex = *p.SingleResult
 		if p.singleResultIndex > len(p.Queries)-1 {
@@ -486,9 +560,22 @@ func (p *PostgresTaskConfig) Validate() (err error) {
 }
 
 type PostgresTaskConfigQuery struct {
-	URL   string   `json:"url"`
-	Query string   `json:"query"`
-	Args  []string `json:"args"`
+	URL        string   `json:"url"`
+	Connection string   `json:"connection"`
+	Query      string   `json:"query"`
+	Args       []string `json:"args"`
+}
+
+/*
+Validate query config
+*/
+func (p *PostgresTaskConfigQuery) Validate() (err error) {
+	p.URL = strings.TrimSpace(p.URL)
+	p.Connection = strings.TrimSpace(p.Connection)
+	if p.URL != "" && p.Connection != "" {
+		return errors.New("please provide either url or connection")
+	}
+	return
 }
 
 /*
@@ -499,17 +586,40 @@ type PostgresTask struct {
 
 	// configuration
 	config *PostgresTaskConfig
+
+	// named connections
+	connections *Connections
+}
+
+/*
+Probes returns checks of databases used by queries
+*/
+func (p *PostgresTask) Probes() (result []Probe) {
+	for _, query := range p.config.Queries {
+		if query.Connection == "" && !isTemplate(query.URL) {
+			result = append(result, sqlProbe("postgres", query.URL))
+		}
+	}
+	return
 }
 
 /*
 Run postgres task
 */
 func (p *PostgresTask) Run(r *http.Request, data map[string]interface{}) (response *Response) {
+	return p.RunContext(r.Context(), r, data)
+}
+
+/*
+RunContext runs all queries
+*/
+func (p *PostgresTask) RunContext(ctx context.Context, r *http.Request, data map[string]interface{}) (response *Response) {
 
 	response = NewResponse(http.StatusOK)
 	queryresults := []*Response{}
+	items := newTaskItems("postgres")
 
-	for _, query := range p.config.Queries {
+	for i, query := range p.config.Queries {
 
 		qresponse := NewResponse(http.StatusOK).StripStatusData()
 
@@ -523,9 +633,11 @@ func (p *PostgresTask) Run(r *http.Request, data map[string]interface{}) (respon
 
 			errq error
 		)
-		if url, err = Interpolate(query.URL, data); err != nil {
-			qresponse.Error(err)
-			goto Append
+		if query.Connection == "" {
+			if url, err = Interpolate(query.URL, data); err != nil {
+				qresponse.Error(err)
+				goto Append
+			}
 		}
 
 		// interpolate all args
@@ -544,8 +656,13 @@ func (p *PostgresTask) Run(r *http.Request, data map[string]interface{}) (respon
 			qresponse.AddValue("query", query.Query).AddValue("args", args)
 		}
 
-		if db, err = sqlx.Connect("postgres", url); err != nil {
+		if query.Connection != "" {
+			db, err = p.connections.SQL(query.Connection)
+		} else {
+			db, err = connectContext(ctx, "postgres", url)
+		}
 
+		if err != nil {
 			if err, ok := err.(*pq.Error); ok {
 				qresponse.AddValue("error_code", err.Code.Name())
 			}
@@ -554,7 +671,7 @@ func (p *PostgresTask) Run(r *http.Request, data map[string]interface{}) (respon
 		}
 
 		// run query
-		rows, errq = db.Queryx(query.Query, args...)
+		rows, errq = queryxContext(ctx, db, query.Query, args...)
 		if errq != nil {
 			if errq, ok := errq.(*pq.Error); ok {
 				qresponse.AddValue("error_code", errq.Code.Name())
@@ -581,6 +698,16 @@ func (p *PostgresTask) Run(r *http.Request, data map[string]interface{}) (respon
 		qresponse.Result(Rows)
 
 	Append:
+		// result set and own connection are closed after every query, so
+		// pooled connection is not held until task finishes
+		if rows != nil {
+			rows.Close()
+		}
+		if db != nil && query.Connection == "" {
+			db.Close()
+		}
+
+		items.Check(ctx, qresponse, "queries[%d]", i)
 		queryresults = append(queryresults, qresponse)
 	}
 
@@ -591,9 +718,34 @@ func (p *PostgresTask) Run(r *http.Request, data map[string]interface{}) (respon
 		response.Result(queryresults)
 	}
 
+	return items.Apply(response)
+}
+
+/*
+connectContext opens database and verifies connection with ping.
+*/
+func connectContext(ctx context.Context, driver, url string) (db *sqlx.DB, err error) {
+	if db, err = sqlx.Open(driver, url); err != nil {
+		return
+	}
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
 	return
 }
 
+/*
+queryxContext runs query on database that is cancelled along with context.
+*/
+func queryxContext(ctx context.Context, db *sqlx.DB, query string, args ...interface{}) (*sqlx.Rows, error) {
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlx.Rows{Rows: rows, Mapper: db.Mapper}, nil
+}
+
 /*
 RedisTask
 
@@ -601,6 +753,7 @@ task to call commands on redis
 */
 
 type RedisTaskConfig struct {
+	Connection        string                 `json:"connection"`
 	Address           string                 `json:"address"`
 	Database          int                    `json:"database"`
 	Network           string                 `json:"network"`
@@ -695,7 +848,7 @@ func RedisTaskFactory(server *Server, tc *TaskConfig, ec *EndpointConfig) (resul
 	}
 
 	// unmarshall config
-	if err = json.Unmarshal(tc.Config, config); err != nil {
+	if err = decodeConfig(tc.Config, config); err != nil {
 		return
 	}
 
@@ -704,9 +857,17 @@ func RedisTaskFactory(server *Server, tc *TaskConfig, ec *EndpointConfig) (resul
 		return
 	}
 
+	// use named connection
+	if config.Connection != "" {
+		if err = server.Config.CheckConnection(config.Connection, "redis"); err != nil {
+			return
+		}
+	}
+
 	result = []Tasker{
 		&RedisTask{
-			config: config,
+			config:      config,
+			connections: server.connections,
 		},
 	}
 	return
@@ -717,14 +878,51 @@ type RedisTask struct {
 
 	// config instance
 	config *RedisTaskConfig
+
+	// named connections
+	connections *Connections
+}
+
+/*
+Probes returns check of redis server
+*/
+func (rt *RedisTask) Probes() (result []Probe) {
+	if rt.config.Connection != "" || isTemplate(rt.config.Address) {
+		return
+	}
+
+	network, address := rt.config.Network, rt.config.Address
+	return []Probe{{
+		Kind: "redis",
+		Name: address,
+		Check: func(ctx context.Context) (err error) {
+			var rc redis.Conn
+			if rc, err = redis.Dial(network, address, redisDialContext(ctx)); err != nil {
+				return
+			}
+			conn := &redisContextConn{conn: rc}
+			defer conn.Close()
+
+			_, err = conn.Do(ctx, "PING")
+			return
+		},
+	}}
 }
 
 /*
 Run method runs when request comes...
 */
 func (rt *RedisTask) Run(r *http.Request, data map[string]interface{}) (response *Response) {
+	return rt.RunContext(r.Context(), r, data)
+}
+
+/*
+RunContext runs all redis commands. Connection is closed when context is done.
+*/
+func (rt *RedisTask) RunContext(ctx context.Context, r *http.Request, data map[string]interface{}) (response *Response) {
 
 	response = NewResponse(http.StatusOK)
+	items := newTaskItems("redis")
 
 	var (
 		address string
@@ -735,19 +933,36 @@ func (rt *RedisTask) Run(r *http.Request, data map[string]interface{}) (response
 		return
 	}
 
-	var conn redis.Conn
-	if conn, err = redis.Dial(rt.config.Network, address); err != nil {
+	var rc redis.Conn
+	if rt.config.Connection != "" {
+		// shared connection pool
+		var pool *RedisConnection
+		if pool, err = rt.connections.Redis(rt.config.Connection); err == nil {
+			rc, err = pool.GetContext(ctx)
+		}
+	} else {
+		rc, err = redis.Dial(rt.config.Network, address, redisDialContext(ctx))
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return response.Status(contextStatus(ctx)).Error(ctx.Err().Error())
+		}
 		response.Error(err)
 		return
 	}
 
+	// redigo doesn't support context, so commands are run with context wrapper
+	conn := &redisContextConn{conn: rc}
+	defer conn.Close()
+
 	queries := []*Response{}
 
 	var (
 		reply interface{}
 		grr   interface{}
 	)
-	for _, query := range rt.config.Queries {
+	for i, query := range rt.config.Queries {
 		qr := NewResponse(http.StatusOK).StripStatusData()
 
 		args := []interface{}{}
@@ -766,7 +981,7 @@ func (rt *RedisTask) Run(r *http.Request, data map[string]interface{}) (response
 			qr.AddValue("args", args)
 		}
 
-		if reply, err = conn.Do(query.Command, args...); err != nil {
+		if reply, err = conn.Do(ctx, query.Command, args...); err != nil {
 			qr.Error(err)
 			goto AddItem
 		}
@@ -785,6 +1000,7 @@ func (rt *RedisTask) Run(r *http.Request, data map[string]interface{}) (response
 		qr.Result(grr)
 
 	AddItem:
+		items.Check(ctx, qr, "queries[%d]", i)
 		queries = append(queries, qr)
 	}
 
@@ -795,7 +1011,74 @@ func (rt *RedisTask) Run(r *http.Request, data map[string]interface{}) (response
 		response.Result(queries)
 	}
 
-	return
+	return items.Apply(response)
+}
+
+/*
+redisContextConn runs redis commands with context. If context is done before
+reply is received, error is returned immediately and connection is closed
+(returned to pool) after command finishes.
+*/
+type redisContextConn struct {
+	lock      sync.Mutex
+	conn      redis.Conn
+	abandoned bool
+}
+
+type redisReply struct {
+	reply interface{}
+	err   error
+}
+
+/*
+Do runs command
+*/
+func (r *redisContextConn) Do(ctx context.Context, command string, args ...interface{}) (reply interface{}, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	result := make(chan redisReply, 1)
+	go func() {
+		reply, err := r.conn.Do(command, args...)
+		result <- redisReply{reply: reply, err: err}
+
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		if r.abandoned {
+			r.conn.Close()
+		}
+	}()
+
+	select {
+	case rr := <-result:
+		return rr.reply, rr.err
+	case <-ctx.Done():
+		r.lock.Lock()
+		r.abandoned = true
+		r.lock.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+/*
+Close closes connection, abandoned connection is closed when command finishes.
+*/
+func (r *redisContextConn) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.abandoned {
+		r.conn.Close()
+	}
+}
+
+/*
+redisDialContext returns dial option that dials with context
+*/
+func redisDialContext(ctx context.Context) redis.DialOption {
+	return redis.DialNetDial(func(network, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	})
 }
 
 func (r *RedisTask) GetReply(reply interface{}, query RedisTaskConfigQuery) (interface{}, error) {
@@ -849,16 +1132,27 @@ func (c *CassandraTaskConfig) Validate() (err error) {
 Config for Query
 */
 type CassandraTaskConfigQuery struct {
-	Cluster  []string `json:"cluster"`
-	Keyspace string   `json:"keyspace"`
-	Query    string   `json:"query"`
-	Args     []string `json:"args"`
+	Connection string   `json:"connection"`
+	Cluster    []string `json:"cluster"`
+	Keyspace   string   `json:"keyspace"`
+	Query      string   `json:"query"`
+	Args       []string `json:"args"`
 }
 
 /*
 Validate query config
 */
 func (c *CassandraTaskConfigQuery) Validate() (err error) {
+	c.Connection = strings.TrimSpace(c.Connection)
+
+	// cluster and keyspace are defined in named connection
+	if c.Connection != "" {
+		if len(c.Cluster) > 0 {
+			return fmt.Errorf("please provide either cluster or connection")
+		}
+		return
+	}
+
 	if len(c.Cluster) == 0 {
 		return fmt.Errorf("cluster must have at least one url")
 	}
@@ -873,15 +1167,25 @@ func (c *CassandraTaskConfigQuery) Validate() (err error) {
 
 func CassandraTaskFactory(s *Server, tc *TaskConfig, ec *EndpointConfig) (result []Tasker, err error) {
 	config := &CassandraTaskConfig{}
-	if err = json.Unmarshal(tc.Config, config); err != nil {
+	if err = decodeConfig(tc.Config, config); err != nil {
 		return
 
 	}
 	if err = config.Validate(); err != nil {
 		return
 	}
+	for _, query := range config.Queries {
+		if query.Connection == "" {
+			continue
+		}
+		if err = s.Config.CheckConnection(query.Connection, "cassandra"); err != nil {
+			return
+		}
+	}
+
 	result = []Tasker{&CassandraTask{
-		config: config,
+		config:      config,
+		connections: s.connections,
 	}}
 	return
 }
@@ -894,30 +1198,94 @@ type CassandraTask struct {
 
 	// configuration
 	config *CassandraTaskConfig
+
+	// named connections
+	connections *Connections
+}
+
+/*
+Probes returns checks of clusters used by queries
+*/
+func (c *CassandraTask) Probes() (result []Probe) {
+Outer:
+	for _, query := range c.config.Queries {
+		if query.Connection != "" || isTemplate(query.Keyspace) {
+			continue
+		}
+		for _, host := range query.Cluster {
+			if isTemplate(host) {
+				continue Outer
+			}
+		}
+
+		hosts, keyspace := query.Cluster, query.Keyspace
+		result = append(result, Probe{
+			Kind: "cassandra",
+			Name: strings.Join(hosts, ",") + "/" + keyspace,
+			Check: func(ctx context.Context) (err error) {
+				cluster := gocql.NewCluster(hosts...)
+				cluster.Keyspace = keyspace
+				if deadline, ok := ctx.Deadline(); ok {
+					cluster.Timeout = time.Until(deadline)
+				}
+
+				var session *gocql.Session
+				if session, err = cluster.CreateSession(); err != nil {
+					return
+				}
+				session.Close()
+				return
+			},
+		})
+	}
+	return
 }
 
 /*
 Run cassandra task
 */
 func (c *CassandraTask) Run(r *http.Request, data map[string]interface{}) (response *Response) {
+	return c.RunContext(r.Context(), r, data)
+}
+
+/*
+RunContext runs all cassandra queries
+*/
+func (c *CassandraTask) RunContext(ctx context.Context, r *http.Request, data map[string]interface{}) (response *Response) {
 
 	response = NewResponse(http.StatusOK)
 
 	queries := []*Response{}
+	items := newTaskItems("cassandra")
 
-	for _, query := range c.config.Queries {
+	for i, query := range c.config.Queries {
 		args := []interface{}{}
 
 		var (
 			Result  []map[string]interface{}
 			cluster *gocql.ClusterConfig
 			session *gocql.Session
+			shared  *CassandraConnection
 			err     error
 		)
 
 		qr := NewResponse(http.StatusOK).StripStatusData()
 
 		chosts := []string{}
+
+		// shared session from named connection
+		if query.Connection != "" {
+			if shared, err = c.connections.Cassandra(query.Connection); err != nil {
+				qr.Error(err)
+				goto Append
+			}
+			if session, err = shared.Session(); err != nil {
+				qr.Error(err)
+				goto Append
+			}
+			goto Query
+		}
+
 		for _, i := range query.Cluster {
 			var chost string
 			if chost, err = Interpolate(i, data); err != nil {
@@ -934,10 +1302,19 @@ func (c *CassandraTask) Run(r *http.Request, data map[string]interface{}) (respo
 			goto Append
 		}
 
+		// don't connect when context is already done
+		if err = ctx.Err(); err != nil {
+			qr.Error(err)
+			goto Append
+		}
+
 		if session, err = cluster.CreateSession(); err != nil {
 			qr.Error(err)
 			goto Append
 		}
+		defer session.Close()
+
+	Query:
 
 		if c.config.ReturnQueries {
 			qr.AddValue("query", query.Query)
@@ -958,7 +1335,7 @@ func (c *CassandraTask) Run(r *http.Request, data map[string]interface{}) (respo
 		}
 
 		// slicemap to result
-		if Result, err = session.Query(query.Query, args...).Iter().SliceMap(); err != nil {
+		if Result, err = session.Query(query.Query, args...).WithContext(ctx).Iter().SliceMap(); err != nil {
 			qr.Error(err)
 			goto Append
 		} else {
@@ -967,6 +1344,7 @@ func (c *CassandraTask) Run(r *http.Request, data map[string]interface{}) (respo
 		}
 
 	Append:
+		items.Check(ctx, qr, "queries[%d]", i)
 		queries = append(queries, qr)
 	}
 
@@ -977,7 +1355,7 @@ func (c *CassandraTask) Run(r *http.Request, data map[string]interface{}) (respo
 		response.Result(queries)
 	}
 
-	return
+	return items.Apply(response)
 }
 
 /*
@@ -1022,15 +1400,20 @@ func (m *MySQLTaskConfig) Validate() (err error) {
 Configuration for single query
 */
 type MySQLTaskConfigQuery struct {
-	URL   string   `json:"url"`
-	Query string   `json:"query"`
-	Args  []string `json:"args"`
+	URL        string   `json:"url"`
+	Connection string   `json:"connection"`
+	Query      string   `json:"query"`
+	Args       []string `json:"args"`
 }
 
 func (m *MySQLTaskConfigQuery) Validate() (err error) {
 	m.URL = strings.TrimSpace(m.URL)
-	if m.URL == "" {
-		return fmt.Errorf("please provide url for query")
+	m.Connection = strings.TrimSpace(m.Connection)
+	if m.URL == "" && m.Connection == "" {
+		return fmt.Errorf("please provide url or connection for query")
+	}
+	if m.URL != "" && m.Connection != "" {
+		return fmt.Errorf("please provide either url or connection for query")
 	}
 
 	m.Query = strings.TrimSpace(m.Query)
@@ -1046,12 +1429,26 @@ Factory to create task
 */
 func MySQLTaskFactory(s *Server, tc *TaskConfig, ec *EndpointConfig) (result []Tasker, err error) {
 	config := &MySQLTaskConfig{}
-	if err = json.Unmarshal(tc.Config, config); err != nil {
+	if err = decodeConfig(tc.Config, config); err != nil {
+		return
+	}
+
+	if err = config.Validate(); err != nil {
 		return
 	}
 
+	for _, query := range config.Queries {
+		if query.Connection == "" {
+			continue
+		}
+		if err = s.Config.CheckConnection(query.Connection, "mysql"); err != nil {
+			return
+		}
+	}
+
 	result = []Tasker{&MySQLTask{
-		config: config,
+		config:      config,
+		connections: s.connections,
 	}}
 	return
 }
@@ -1064,26 +1461,46 @@ type MySQLTask struct {
 
 	// configuration
 	config *MySQLTaskConfig
+
+	// named connections
+	connections *Connections
+}
+
+/*
+Probes returns checks of databases used by queries
+*/
+func (m *MySQLTask) Probes() (result []Probe) {
+	for _, query := range m.config.Queries {
+		if query.Connection == "" && !isTemplate(query.URL) {
+			result = append(result, sqlProbe("mysql", query.URL))
+		}
+	}
+	return
 }
 
 /*
 Run mysql task.
 */
 func (m *MySQLTask) Run(r *http.Request, data map[string]interface{}) (response *Response) {
+	return m.RunContext(r.Context(), r, data)
+}
+
+/*
+RunContext runs all mysql queries
+*/
+func (m *MySQLTask) RunContext(ctx context.Context, r *http.Request, data map[string]interface{}) (response *Response) {
 
 	response = NewResponse(http.StatusOK)
 
 	queries := []*Response{}
+	items := newTaskItems("mysql")
 
-	var (
-		db   *sqlx.DB
-		rows *sqlx.Rows
-		err  error
-	)
-
-	for _, query := range m.config.Queries {
+	for i, query := range m.config.Queries {
 
 		var (
+			db   *sqlx.DB
+			rows *sqlx.Rows
+			err  error
 			Rows []map[string]interface{}
 		)
 
@@ -1092,16 +1509,24 @@ func (m *MySQLTask) Run(r *http.Request, data map[string]interface{}) (response
 		qr := NewResponse(http.StatusOK).StripStatusData()
 
 		var url string
-		if url, err = Interpolate(query.URL, data); err != nil {
-			qr.Error(err)
-			goto Append
+		if query.Connection == "" {
+			if url, err = Interpolate(query.URL, data); err != nil {
+				qr.Error(err)
+				goto Append
+			}
 		}
 
 		if m.config.ReturnQueries {
 			qr.AddValue("query", query.Query)
 		}
 
-		if db, err = sqlx.Open("mysql", url); err != nil {
+		if query.Connection != "" {
+			db, err = m.connections.SQL(query.Connection)
+		} else {
+			db, err = sqlx.Open("mysql", url)
+		}
+
+		if err != nil {
 			qr.Error(err.Error())
 			if err, ok := err.(*mysql.MySQLError); ok {
 				qr.AddValue("error_code", err.Number)
@@ -1125,7 +1550,7 @@ func (m *MySQLTask) Run(r *http.Request, data map[string]interface{}) (response
 		}
 
 		// run query
-		rows, err = db.Queryx(query.Query, args...)
+		rows, err = queryxContext(ctx, db, query.Query, args...)
 		if err != nil {
 			qr.Error(err)
 			if err, ok := err.(*mysql.MySQLError); ok {
@@ -1147,6 +1572,16 @@ func (m *MySQLTask) Run(r *http.Request, data map[string]interface{}) (response
 		qr.Result(Rows)
 
 	Append:
+		// result set and own connection are closed after every query, so
+		// pooled connection is not held until task finishes
+		if rows != nil {
+			rows.Close()
+		}
+		if db != nil && query.Connection == "" {
+			db.Close()
+		}
+
+		items.Check(ctx, qr, "queries[%d]", i)
 		queries = append(queries, qr)
 	}
 
@@ -1157,7 +1592,7 @@ func (m *MySQLTask) Run(r *http.Request, data map[string]interface{}) (response
 		response.Result(queries)
 	}
 
-	return
+	return items.Apply(response)
 }
 
 /*
@@ -1167,7 +1602,7 @@ func MultiTaskFactory(s *Server, tc *TaskConfig, ec *EndpointConfig) (result []T
 	config := &MultiTaskConfig{
 		Tasks: []*TaskConfig{},
 	}
-	if err = json.Unmarshal(tc.Config, config); err != nil {
+	if err = decodeConfig(tc.Config, config); err != nil {
 		return
 	}
 
@@ -1176,19 +1611,29 @@ func MultiTaskFactory(s *Server, tc *TaskConfig, ec *EndpointConfig) (result []T
 	}
 
 	mt := &MultiTask{
-		config: config,
-		tasks:  []Tasker{},
+		config:   config,
+		tasks:    []ContextTasker{},
+		types:    []string{},
+		timeouts: []Duration{},
 	}
 
-	for _, mtc := range config.Tasks {
+	for i, mtc := range config.Tasks {
+		source := configSource{Pointer: fmt.Sprintf("/tasks/%d", i)}
+
+		// apply task template
+		if err = s.Config.extendTask(mtc); err != nil {
+			err = source.Wrap(err, "/extends")
+			return
+		}
 
 		if mtc.Type == "multi" {
-			err = errors.New("multi task does not support embedded multi tasks")
+			err = source.Wrap(errors.New("multi task does not support embedded multi tasks"), "/type")
 			return
 		}
 
 		// validate task config
 		if err = mtc.Validate(); err != nil {
+			err = source.Wrap(err, "")
 			return
 		}
 
@@ -1199,18 +1644,20 @@ func MultiTaskFactory(s *Server, tc *TaskConfig, ec *EndpointConfig) (result []T
 		)
 
 		if factory, ok = getTaskFactory(mtc.Type); !ok {
-			err = fmt.Errorf("task %s doesn't exist", mtc.Type)
+			err = source.Wrap(fmt.Errorf("task %s doesn't exist", mtc.Type), "/type")
 			return
 		}
 
 		if tasks, err = factory(s, mtc, ec); err != nil {
-			err = fmt.Errorf("task %s returned error: %s", mtc.Type, err)
+			err = source.Wrap(err, "/config")
 			return
 		}
 
 		// append all tasks
 		for _, t := range tasks {
-			mt.tasks = append(mt.tasks, t)
+			mt.tasks = append(mt.tasks, NewContextTasker(t))
+			mt.types = append(mt.types, mtc.Type)
+			mt.timeouts = append(mt.timeouts, mtc.Timeout)
 		}
 	}
 
@@ -1248,20 +1695,50 @@ type MultiTask struct {
 
 	// configuration
 	config *MultiTaskConfig
-	tasks  []Tasker
+	tasks  []ContextTasker
+	types  []string
+
+	// timeouts of tasks
+	timeouts []Duration
+}
+
+/*
+Probes returns checks of all tasks
+*/
+func (m *MultiTask) Probes() (result []Probe) {
+	for _, tasker := range m.tasks {
+		if prober, ok := tasker.(Prober); ok {
+			result = append(result, prober.Probes()...)
+		}
+	}
+	return
 }
 
 /*
 Run multi task.
 */
 func (m *MultiTask) Run(r *http.Request, data map[string]interface{}) (response *Response) {
+	return m.RunContext(r.Context(), r, data)
+}
+
+/*
+RunContext runs all tasks, every task can have its own timeout.
+*/
+func (m *MultiTask) RunContext(ctx context.Context, r *http.Request, data map[string]interface{}) (response *Response) {
 
 	response = NewResponse(http.StatusOK)
 
 	results := []*Response{}
+	items := newTaskItems("multi")
+
+	for i, tasker := range m.tasks {
+		tctx, cancel := taskContext(ctx, m.timeouts[i])
+		started := time.Now()
+		tr := tasker.RunContext(tctx, r, data)
+		metrics.taskDuration.Observe(time.Since(started).Seconds(), m.types[i])
+		items.Check(tctx, tr, "tasks[%d]", i)
+		cancel()
 
-	for _, tasker := range m.tasks {
-		tr := tasker.Run(r, data)
 		results = append(results, tr)
 	}
 
@@ -1271,7 +1748,7 @@ func (m *MultiTask) Run(r *http.Request, data map[string]interface{}) (response
 		response.Result(results)
 	}
 
-	return
+	return items.Apply(response)
 }
 
 /*
@@ -1305,10 +1782,35 @@ type FilesystemTask struct {
 	config *FilesystemConfig
 }
 
+/*
+Probes returns check of served file or directory
+*/
+func (f *FilesystemTask) Probes() (result []Probe) {
+	if isTemplate(f.config.Directory) {
+		return
+	}
+
+	if f.config.File != "" && !isTemplate(f.config.File) {
+		return []Probe{fileProbe(filepath.Join(f.config.Directory, f.config.File), false)}
+	}
+
+	if f.config.Directory != "" {
+		return []Probe{fileProbe(f.config.Directory, true)}
+	}
+	return
+}
+
 /*
 Run method for FilesystemTask
 */
 func (f *FilesystemTask) Run(r *http.Request, data map[string]interface{}) (response *Response) {
+	return f.RunContext(r.Context(), r, data)
+}
+
+/*
+RunContext serves file or directory index
+*/
+func (f *FilesystemTask) RunContext(ctx context.Context, r *http.Request, data map[string]interface{}) (response *Response) {
 
 	var (
 		directory string
@@ -1334,6 +1836,11 @@ func (f *FilesystemTask) Run(r *http.Request, data map[string]interface{}) (resp
 
 	full := filepath.Join(directory, filename)
 
+	// filesystem operations don't support context, check it at least before them
+	if err = ctx.Err(); err != nil {
+		return response.Status(contextStatus(ctx)).Error(err.Error()).AddValue("timed_out", []string{full})
+	}
+
 	if finfo, err = os.Stat(full); err != nil {
 		return response.Status(http.StatusNotFound)
 	}
@@ -1363,6 +1870,10 @@ func (f *FilesystemTask) Run(r *http.Request, data map[string]interface{}) (resp
 		return response.Result(results)
 	}
 
+	if err = ctx.Err(); err != nil {
+		return response.Status(contextStatus(ctx)).Error(err.Error()).AddValue("timed_out", []string{full})
+	}
+
 	var contents []byte
 	if contents, err = ioutil.ReadFile(full); err != nil {
 		return response.Status(http.StatusInternalServerError).Error(err)
@@ -1389,7 +1900,7 @@ Factory to create filesystem tasks
 func FilesystemFactory(s *Server, tc *TaskConfig, ec *EndpointConfig) (result []Tasker, err error) {
 
 	config := NewFilesystemConfig()
-	if err = json.Unmarshal(tc.Config, config); err != nil {
+	if err = decodeConfig(tc.Config, config); err != nil {
 		return
 	}
 
@@ -0,0 +1,80 @@
+package goexpose
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMultiTask(t *testing.T) {
+
+	Convey("Test timed out tasks", t, func() {
+		mt := &MultiTask{
+			config: &MultiTaskConfig{singleResultIndex: -1},
+			tasks: []ContextTasker{
+				NewContextTasker(&sleepTask{duration: 100 * time.Millisecond}),
+				&timedOutTask{},
+				NewContextTasker(&sleepTask{}),
+			},
+			types:    []string{"sleep", "shell", "sleep"},
+			timeouts: []Duration{Duration(10 * time.Millisecond), Duration(10 * time.Millisecond), 0},
+		}
+
+		request, _ := http.NewRequest("GET", "/multi", nil)
+		response := mt.Run(request, map[string]interface{}{})
+		So(response.GetStatus(), ShouldEqual, http.StatusGatewayTimeout)
+
+		// items of sub tasks that timed out are prefixed with task
+		So(response.data["timed_out"], ShouldResemble, []string{"tasks[0]", "tasks[1].commands[1]"})
+
+		results := response.data["result"].([]*Response)
+		So(results[0].data["timeout"], ShouldEqual, true)
+		So(results[2].GetStatus(), ShouldEqual, http.StatusOK)
+	})
+
+	Convey("Test tasks without timeout", t, func() {
+		mt := &MultiTask{
+			config:   &MultiTaskConfig{singleResultIndex: -1},
+			tasks:    []ContextTasker{NewContextTasker(&sleepTask{})},
+			types:    []string{"sleep"},
+			timeouts: []Duration{0},
+		}
+
+		request, _ := http.NewRequest("GET", "/multi", nil)
+		response := mt.Run(request, map[string]interface{}{})
+		So(response.GetStatus(), ShouldEqual, http.StatusOK)
+		So(response.HasValue("timed_out"), ShouldBeFalse)
+	})
+}
+
+/*
+sleepTask sleeps for given duration, it does not support context
+*/
+type sleepTask struct {
+	Task
+	duration time.Duration
+}
+
+func (s *sleepTask) Run(r *http.Request, vars map[string]interface{}) *Response {
+	time.Sleep(s.duration)
+	return NewResponse(http.StatusOK)
+}
+
+/*
+timedOutTask waits for context and reports timed out item as tasks with items do
+*/
+type timedOutTask struct {
+	Task
+}
+
+func (t *timedOutTask) Run(r *http.Request, vars map[string]interface{}) *Response {
+	return t.RunContext(r.Context(), r, vars)
+}
+
+func (t *timedOutTask) RunContext(ctx context.Context, r *http.Request, vars map[string]interface{}) *Response {
+	<-ctx.Done()
+	return NewResponse(contextStatus(ctx)).Error(ctx.Err().Error()).AddValue("timed_out", []string{"commands[1]"})
+}
@@ -0,0 +1,232 @@
+package goexpose
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+Task templates and endpoint groups
+
+Task can extend named task template from "task_templates", values set in task
+override values from template and "config" is merged deeply (objects are merged,
+other values are replaced). Templates can extend other templates.
+
+Endpoint groups apply shared path prefix, authorizers, query params and default
+task type to their endpoints. Endpoints of groups are added to endpoints when
+configuration is loaded.
+*/
+
+/*
+EndpointGroupConfig is group of endpoints with shared settings
+*/
+type EndpointGroupConfig struct {
+	// prefix of paths of all endpoints
+	Prefix string `json:"prefix"`
+
+	// authorizers applied before authorizers of endpoint
+	Authorizers []string `json:"authorizers"`
+
+	// query params, params of endpoint with same name override them
+	QueryParams *QueryParams `json:"query_params"`
+
+	// default task type
+	Type string `json:"type"`
+
+	Endpoints []*EndpointConfig `json:"endpoints"`
+}
+
+/*
+Endpoint returns copy of endpoint config with group settings applied
+*/
+func (g *EndpointGroupConfig) Endpoint(ec *EndpointConfig) *EndpointConfig {
+	result := *ec
+
+	if ec.Path == "" {
+		result.Path = g.Prefix
+	} else {
+		result.Path = strings.TrimRight(g.Prefix, "/") + ec.Path
+	}
+
+	result.Authorizers = []string{}
+	added := map[string]bool{}
+	for _, authorizer := range append(append([]string{}, g.Authorizers...), ec.Authorizers...) {
+		if !added[authorizer] {
+			added[authorizer] = true
+			result.Authorizers = append(result.Authorizers, authorizer)
+		}
+	}
+
+	if strings.TrimSpace(result.Type) == "" {
+		result.Type = g.Type
+	}
+
+	result.QueryParams = mergeQueryParams(g.QueryParams, ec.QueryParams)
+	return &result
+}
+
+/*
+mergeQueryParams returns query params of group with params of endpoint, params
+of endpoint override params of group with same name and return_params of
+endpoint overrides return_params of group.
+*/
+func mergeQueryParams(group, endpoint *QueryParams) *QueryParams {
+	if group == nil {
+		return endpoint
+	}
+	if endpoint == nil {
+		return group
+	}
+
+	result := &QueryParams{
+		ReturnParams: endpoint.ReturnParams,
+		Params:       []*QueryParamsConfigParam{},
+	}
+
+	names := map[string]bool{}
+	for _, param := range endpoint.Params {
+		if param != nil {
+			names[param.Name] = true
+		}
+	}
+	for _, param := range group.Params {
+		if param != nil && !names[param.Name] {
+			result.Params = append(result.Params, param)
+		}
+	}
+	result.Params = append(result.Params, endpoint.Params...)
+	return result
+}
+
+/*
+applyTaskTemplates applies task templates to tasks of all endpoints
+*/
+func (c *Config) applyTaskTemplates() error {
+	errs := ConfigErrors{}
+	for i, ec := range c.Endpoints {
+		if ec == nil {
+			continue
+		}
+
+		methods := make([]string, 0, len(ec.Methods))
+		for method := range ec.Methods {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			tc := ec.Methods[method]
+			if err := c.extendTask(&tc); err != nil {
+				errs.Add(c.endpointSource(i).WrapEndpoint(err, "/methods/"+escapePointer(method)+"/extends", ec.Path, method))
+				continue
+			}
+			ec.Methods[method] = tc
+		}
+	}
+	return errs.Err()
+}
+
+/*
+extendTask merges task with template it extends
+*/
+func (c *Config) extendTask(tc *TaskConfig) (err error) {
+	if tc.Extends == "" {
+		return
+	}
+
+	var template TaskConfig
+	if template, err = c.taskTemplate(tc.Extends, nil); err != nil {
+		return
+	}
+
+	*tc, err = mergeTaskConfig(template, *tc)
+	return
+}
+
+/*
+taskTemplate returns task template with all templates it extends applied
+*/
+func (c *Config) taskTemplate(name string, seen []string) (result TaskConfig, err error) {
+	for _, s := range seen {
+		if s == name {
+			return result, fmt.Errorf("task template cycle %s", strings.Join(append(seen, name), " -> "))
+		}
+	}
+
+	template, ok := c.TaskTemplates[name]
+	if !ok || template == nil {
+		return result, fmt.Errorf("unknown task template %s", name)
+	}
+
+	if template.Extends == "" {
+		result = *template
+		return
+	}
+
+	var parent TaskConfig
+	if parent, err = c.taskTemplate(template.Extends, append(seen, name)); err != nil {
+		return
+	}
+	return mergeTaskConfig(parent, *template)
+}
+
+/*
+mergeTaskConfig returns task config with values from base that are not set in
+override, config is merged deeply.
+*/
+func mergeTaskConfig(base, override TaskConfig) (result TaskConfig, err error) {
+	result = base
+	result.Extends = ""
+
+	if override.Type != "" {
+		result.Type = override.Type
+	}
+	if override.Authorizers != nil {
+		result.Authorizers = override.Authorizers
+	}
+	if override.QueryParams != nil {
+		result.QueryParams = override.QueryParams
+	}
+	if override.Description != "" {
+		result.Description = override.Description
+	}
+	if override.Timeout != 0 {
+		result.Timeout = override.Timeout
+	}
+
+	result.Config, err = mergeJSON(base.Config, override.Config)
+	return
+}
+
+/*
+mergeJSON merges override into base, objects are merged recursively, all other
+values in override replace values in base.
+*/
+func mergeJSON(base, override json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(override)) == 0 {
+		return base, nil
+	}
+	if len(bytes.TrimSpace(base)) == 0 {
+		return override, nil
+	}
+
+	var baseObject, overrideObject map[string]json.RawMessage
+	if json.Unmarshal(base, &baseObject) != nil || json.Unmarshal(override, &overrideObject) != nil {
+		return override, nil
+	}
+	if baseObject == nil || overrideObject == nil {
+		return override, nil
+	}
+
+	for key, value := range overrideObject {
+		merged, err := mergeJSON(baseObject[key], value)
+		if err != nil {
+			return nil, err
+		}
+		baseObject[key] = merged
+	}
+	return json.Marshal(baseObject)
+}
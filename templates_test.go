@@ -0,0 +1,121 @@
+package goexpose
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTemplates(t *testing.T) {
+
+	Convey("Test merge json", t, func() {
+		merged, err := mergeJSON(json.RawMessage(`{"a": {"b": 1, "c": [1]}, "d": 1}`), json.RawMessage(`{"a": {"c": [2]}, "e": 2}`))
+		So(err, ShouldBeNil)
+		So(string(merged), ShouldEqual, `{"a":{"b":1,"c":[2]},"d":1,"e":2}`)
+
+		merged, err = mergeJSON(json.RawMessage(`{"a": 1}`), nil)
+		So(err, ShouldBeNil)
+		So(string(merged), ShouldEqual, `{"a": 1}`)
+	})
+
+	Convey("Test extend task", t, func() {
+		config := NewConfig()
+		config.TaskTemplates = map[string]*TaskConfig{
+			"base":  {Type: "shell", Description: "base", Config: json.RawMessage(`{"shell": "sh"}`)},
+			"child": {Extends: "base", Description: "child"},
+			"cycle": {Extends: "cycle"},
+		}
+
+		tc := &TaskConfig{Extends: "child", Config: json.RawMessage(`{"env": {"A": "B"}}`)}
+		So(config.extendTask(tc), ShouldBeNil)
+		So(tc.Type, ShouldEqual, "shell")
+		So(tc.Description, ShouldEqual, "child")
+		So(tc.Extends, ShouldEqual, "")
+		So(string(tc.Config), ShouldEqual, `{"env":{"A":"B"},"shell":"sh"}`)
+
+		So(config.extendTask(&TaskConfig{Extends: "cycle"}), ShouldNotBeNil)
+		So(config.extendTask(&TaskConfig{Extends: "unknown"}), ShouldNotBeNil)
+	})
+
+	Convey("Test endpoint group", t, func() {
+		group := &EndpointGroupConfig{
+			Prefix:      "/api/",
+			Authorizers: []string{"a"},
+			Type:        "shell",
+			QueryParams: &QueryParams{ReturnParams: true, Params: []*QueryParamsConfigParam{{Name: "x"}, {Name: "y"}}},
+		}
+		ec := group.Endpoint(&EndpointConfig{
+			Path:        "/users",
+			Authorizers: []string{"b", "a"},
+			QueryParams: &QueryParams{Params: []*QueryParamsConfigParam{{Name: "y", Default: "1"}}},
+		})
+		So(ec.Path, ShouldEqual, "/api/users")
+		So(ec.Authorizers, ShouldResemble, []string{"a", "b"})
+		So(ec.Type, ShouldEqual, "shell")
+		So(len(ec.QueryParams.Params), ShouldEqual, 2)
+		So(ec.QueryParams.Params[1].Default, ShouldEqual, "1")
+
+		// return_params of endpoint overrides group
+		So(ec.QueryParams.ReturnParams, ShouldBeFalse)
+
+		ec = group.Endpoint(&EndpointConfig{Path: "/groups"})
+		So(ec.QueryParams.ReturnParams, ShouldBeTrue)
+	})
+
+	Convey("Test endpoint type", t, func() {
+		ec := &EndpointConfig{Type: "shell", Methods: map[string]TaskConfig{"GET": {}}}
+		So(ec.Validate(), ShouldBeNil)
+		So(ec.Methods["GET"].Type, ShouldEqual, "shell")
+	})
+
+	Convey("Test task templates of configuration built in code", t, func() {
+		config := NewConfig()
+		config.TaskTemplates = map[string]*TaskConfig{
+			"info": {Type: "info", Description: "info template"},
+		}
+		config.Endpoints = []*EndpointConfig{
+			{Path: "/info", Methods: map[string]TaskConfig{"GET": {Extends: "info"}}},
+		}
+
+		server, err := NewServer(config)
+		So(err, ShouldBeNil)
+		So(config.Endpoints[0].Methods["GET"].Type, ShouldEqual, "info")
+		So(config.Endpoints[0].Methods["GET"].Description, ShouldEqual, "info template")
+
+		request, _ := http.NewRequest("GET", "/info", nil)
+		response, err := server.RunRequest(request, true)
+		So(err, ShouldBeNil)
+		So(response.Code, ShouldEqual, http.StatusOK)
+
+		config.Endpoints[0].Methods["GET"] = TaskConfig{Extends: "unknown"}
+		_, err = NewServer(config)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Test info task in multi task extending template", t, func() {
+		config := NewConfig()
+		config.TaskTemplates = map[string]*TaskConfig{
+			"info": {Type: "info"},
+		}
+		config.Endpoints = []*EndpointConfig{
+			{Path: "/multi", Methods: map[string]TaskConfig{"GET": {
+				Type:   "multi",
+				Config: json.RawMessage(`{"tasks": [{"extends": "info"}]}`),
+			}}},
+		}
+
+		So(config.taskUsesType(&TaskConfig{Extends: "info"}, "info"), ShouldBeTrue)
+		tc := config.Endpoints[0].Methods["GET"]
+		So(config.taskUsesType(&tc, "info"), ShouldBeTrue)
+
+		server, err := NewServer(config)
+		So(err, ShouldBeNil)
+
+		request, _ := http.NewRequest("GET", "/multi", nil)
+		response, err := server.RunRequest(request, true)
+		So(err, ShouldBeNil)
+		So(response.Code, ShouldEqual, http.StatusOK)
+	})
+}
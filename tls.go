@@ -0,0 +1,214 @@
+package goexpose
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// supported client_auth values
+	sslClientAuth = map[string]tls.ClientAuthType{
+		"":         tls.NoClientCert,
+		"none":     tls.NoClientCert,
+		"optional": tls.VerifyClientCertIfGiven,
+		"required": tls.RequireAndVerifyClientCert,
+	}
+
+	// supported min_version values
+	sslVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+)
+
+/*
+Validate validates ssl configuration
+*/
+func (s *SSLConfig) Validate() (err error) {
+	s.Cert = strings.TrimSpace(s.Cert)
+	s.Key = strings.TrimSpace(s.Key)
+	if s.Cert == "" || s.Key == "" {
+		return fmt.Errorf("ssl: please provide cert and key")
+	}
+
+	s.ClientAuth = strings.TrimSpace(strings.ToLower(s.ClientAuth))
+	if _, ok := sslClientAuth[s.ClientAuth]; !ok {
+		return fmt.Errorf("ssl: unknown client_auth %s", s.ClientAuth)
+	}
+
+	if s.ClientAuth != "" && s.ClientAuth != "none" && len(s.ClientCA) == 0 {
+		return fmt.Errorf("ssl: client_auth %s needs client_ca", s.ClientAuth)
+	}
+
+	s.MinVersion = strings.TrimSpace(s.MinVersion)
+	if _, ok := sslVersions[s.MinVersion]; !ok && s.MinVersion != "" {
+		return fmt.Errorf("ssl: unknown min_version %s", s.MinVersion)
+	}
+
+	if _, err = cipherSuites(s.Ciphers); err != nil {
+		return
+	}
+
+	if s.ReloadInterval < 0 {
+		return fmt.Errorf("ssl: invalid reload_interval")
+	}
+
+	return
+}
+
+/*
+TLSConfig returns tls configuration. Certificate is not loaded, it's
+responsibility of caller.
+*/
+func (s *SSLConfig) TLSConfig() (result *tls.Config, err error) {
+	result = &tls.Config{
+		ClientAuth: sslClientAuth[s.ClientAuth],
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if s.MinVersion != "" {
+		result.MinVersion = sslVersions[s.MinVersion]
+	}
+
+	if result.CipherSuites, err = cipherSuites(s.Ciphers); err != nil {
+		return
+	}
+
+	if len(s.ClientCA) == 0 {
+		return
+	}
+
+	// load client ca bundles
+	result.ClientCAs = x509.NewCertPool()
+	for _, filename := range s.ClientCA {
+		var body []byte
+		if body, err = ioutil.ReadFile(filename); err != nil {
+			return
+		}
+		if !result.ClientCAs.AppendCertsFromPEM(body) {
+			return nil, fmt.Errorf("ssl: no certificates found in client_ca %s", filename)
+		}
+	}
+
+	return
+}
+
+/*
+cipherSuites returns ids of cipher suites by their names
+*/
+func cipherSuites(names []string) (result []uint16, err error) {
+	if len(names) == 0 {
+		return
+	}
+
+	available := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		available[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		available[suite.Name] = suite.ID
+	}
+
+	for _, name := range names {
+		id, ok := available[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("ssl: unknown cipher %s", name)
+		}
+		result = append(result, id)
+	}
+
+	return
+}
+
+/*
+newCertReloader loads certificate and starts watching cert and key files.
+When files change, certificate is reloaded without dropping connections.
+*/
+func newCertReloader(config *SSLConfig) (result *certReloader, err error) {
+	result = &certReloader{
+		cert: config.Cert,
+		key:  config.Key,
+	}
+
+	if err = result.load(); err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(config.ReloadInterval)
+	if interval == 0 {
+		interval = DEFAULT_CERT_RELOAD_INTERVAL
+	}
+
+	result.watcher = newFileWatcher(interval, func(changed []string) {
+		logger().Infof("Certificate files %v changed, reloading", changed)
+		if err := result.load(); err != nil {
+			logger().Errorf("Reload of certificate %s failed, keeping old certificate: %v", result.cert, err)
+
+			// cert and key can be rotated one after another, try again on next check
+			result.watcher.Reset()
+		}
+	}, config.Cert, config.Key)
+	result.watcher.Start()
+
+	return
+}
+
+/*
+certReloader holds current certificate
+*/
+type certReloader struct {
+	lock        sync.RWMutex
+	cert        string
+	key         string
+	certificate *tls.Certificate
+	watcher     *fileWatcher
+}
+
+/*
+load loads key pair from files
+*/
+func (c *certReloader) load() (err error) {
+	var certificate tls.Certificate
+	if certificate, err = tls.LoadX509KeyPair(c.cert, c.key); err != nil {
+		return
+	}
+
+	var leaf *x509.Certificate
+	if leaf, err = x509.ParseCertificate(certificate.Certificate[0]); err != nil {
+		return
+	}
+	certificate.Leaf = leaf
+
+	c.lock.Lock()
+	c.certificate = &certificate
+	c.lock.Unlock()
+
+	logger().Infof("Loaded certificate %s (subject: %s), expires %v", c.cert, leaf.Subject.CommonName, leaf.NotAfter)
+	if time.Now().After(leaf.NotAfter) {
+		logger().Warningf("Certificate %s has expired", c.cert)
+	}
+	return
+}
+
+/*
+GetCertificate returns current certificate, it's used in tls.Config
+*/
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.certificate, nil
+}
+
+/*
+Stop stops watching certificate files
+*/
+func (c *certReloader) Stop() {
+	c.watcher.Stop()
+}
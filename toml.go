@@ -0,0 +1,55 @@
+package goexpose
+
+import (
+	"math"
+	"time"
+)
+
+/*
+plainTOML converts decoded toml value to value that can be marshalled to json.
+Dates and times are returned as strings in the same form as they were written,
+infinity and nan (not supported by json) are returned as strings.
+*/
+func plainTOML(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			result[key] = plainTOML(item)
+		}
+		return result
+	case []map[string]interface{}:
+		result := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			result = append(result, plainTOML(item))
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			result = append(result, plainTOML(item))
+		}
+		return result
+	case float64:
+		switch {
+		case math.IsNaN(value):
+			return "nan"
+		case math.IsInf(value, 1):
+			return "inf"
+		case math.IsInf(value, -1):
+			return "-inf"
+		}
+	case time.Time:
+		// local dates and times are decoded with special locations
+		switch value.Location().String() {
+		case "date-local":
+			return value.Format("2006-01-02")
+		case "time-local":
+			return value.Format("15:04:05.999999999")
+		case "datetime-local":
+			return value.Format("2006-01-02T15:04:05.999999999")
+		}
+		return value.Format(time.RFC3339Nano)
+	}
+	return value
+}
@@ -0,0 +1,151 @@
+package goexpose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTOML(t *testing.T) {
+
+	unmarshal := configFormats["toml"]
+
+	Convey("Test parse configuration", t, func() {
+		config := NewConfig()
+		err := unmarshal([]byte(`
+host = "127.0.0.1" # comment
+port = 9_980
+pretty_json = true
+
+[authorizers.basic]
+type = "basic"
+config = { username = "user", password = 'pa\ss' }
+
+[[endpoints]]
+path = "/info"
+methods.GET = { type = "info", description = """
+Info task""" }
+
+[[endpoints]]
+path = "/shell"
+[endpoints.methods.POST]
+type = "shell"
+config.commands = [
+    { command = "echo hello" },
+]
+`), config)
+		So(err, ShouldBeNil)
+		So(config.Host, ShouldEqual, "127.0.0.1")
+		So(config.Port, ShouldEqual, 9980)
+		So(config.PrettyJson, ShouldBeTrue)
+		So(string(config.Authorizers["basic"].Config), ShouldEqual, `{"password":"pa\\ss","username":"user"}`)
+		So(len(config.Endpoints), ShouldEqual, 2)
+		So(config.Endpoints[0].Methods["GET"].Description, ShouldEqual, "Info task")
+		So(string(config.Endpoints[1].Methods["POST"].Config), ShouldEqual, `{"commands":[{"command":"echo hello"}]}`)
+	})
+
+	Convey("Test numbers", t, func() {
+		document := map[string]interface{}{}
+		err := unmarshal([]byte(`
+hex = 0xff
+oct = 0o17
+bin = 0b101
+negative = -1_000
+float = 6.626e-34
+positive_inf = +inf
+negative_inf = -inf
+not_a_number = nan
+`), &document)
+		So(err, ShouldBeNil)
+		So(document["hex"], ShouldEqual, 255.0)
+		So(document["oct"], ShouldEqual, 15.0)
+		So(document["bin"], ShouldEqual, 5.0)
+		So(document["negative"], ShouldEqual, -1000.0)
+		So(document["float"], ShouldEqual, 6.626e-34)
+
+		// json has no infinity and nan
+		So(document["positive_inf"], ShouldEqual, "inf")
+		So(document["negative_inf"], ShouldEqual, "-inf")
+		So(document["not_a_number"], ShouldEqual, "nan")
+	})
+
+	Convey("Test dates and times", t, func() {
+		document := map[string]interface{}{}
+		err := unmarshal([]byte(`
+offset = 1979-05-27T07:32:00-07:00
+utc = 1979-05-27 07:32:00Z
+local = 1979-05-27T07:32:00.5
+date = 1979-05-27
+time = 07:32:00
+`), &document)
+		So(err, ShouldBeNil)
+		So(document["offset"], ShouldEqual, "1979-05-27T07:32:00-07:00")
+		So(document["utc"], ShouldEqual, "1979-05-27T07:32:00Z")
+		So(document["local"], ShouldEqual, "1979-05-27T07:32:00.5")
+		So(document["date"], ShouldEqual, "1979-05-27")
+		So(document["time"], ShouldEqual, "07:32:00")
+	})
+
+	Convey("Test arrays and tables", t, func() {
+		document := map[string]interface{}{}
+		err := unmarshal([]byte(`
+list = [1, "two", [3], { four = 4 }]
+
+[[items]]
+name = "a"
+
+[[items]]
+name = "b"
+[items.sub]
+value = true
+`), &document)
+		So(err, ShouldBeNil)
+		So(document["list"], ShouldResemble, []interface{}{
+			1.0, "two", []interface{}{3.0}, map[string]interface{}{"four": 4.0},
+		})
+		So(document["items"], ShouldResemble, []interface{}{
+			map[string]interface{}{"name": "a"},
+			map[string]interface{}{"name": "b", "sub": map[string]interface{}{"value": true}},
+		})
+	})
+
+	Convey("Test invalid documents", t, func() {
+		for _, document := range []string{
+			"a = 1\na = 2",
+			"[a]\n[a]",
+			"a = \"unterminated",
+			"a = [1 2]",
+			"a = { x = 1 }\n[a]",
+			"a = 1 b",
+			"n = 1_000_",
+			"n = 01",
+			"a = 1979-13-27",
+			"a = \"\\q\"",
+		} {
+			err := unmarshal([]byte(document), &map[string]interface{}{})
+			So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("Test error location", t, func() {
+		err := unmarshal([]byte("a = 1\nb = \n"), &map[string]interface{}{})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "line 2")
+	})
+
+	Convey("Test format detected by extension", t, func() {
+		directory, err := ioutil.TempDir("", "goexpose")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(directory)
+
+		filename := filepath.Join(directory, "config.toml")
+		So(ioutil.WriteFile(filename, []byte("port = 9981\n"), 0600), ShouldBeNil)
+
+		config, err := NewConfigFromFilename(filename, "")
+		So(err, ShouldBeNil)
+		So(config.Port, ShouldEqual, 9981)
+	})
+}
@@ -0,0 +1,588 @@
+package goexpose
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Tracing module
+
+Every request has request id (X-Request-ID header) and w3c trace context
+(traceparent header). Both are accepted from client or generated, exposed to
+tasks and returned in response. When "tracing" is configured, spans for
+requests, tasks and task items are exported to otlp collector (http/json)
+or to file.
+*/
+
+const (
+	// request id header
+	requestIDHeader = "X-Request-ID"
+
+	// w3c trace context header
+	traceparentHeader = "traceparent"
+
+	// span kinds as defined by otlp
+	spanKindInternal = 1
+	spanKindServer   = 2
+)
+
+var (
+	// current tracer, nil when tracing is not configured
+	currentTracer     *tracer
+	currentTracerLock sync.RWMutex
+)
+
+/*
+traceContext identifies span in trace
+*/
+type traceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+/*
+newTraceContext returns trace context with new trace id and span id
+*/
+func newTraceContext() traceContext {
+	result := traceContext{Flags: 1}
+	rand.Read(result.TraceID[:])
+	rand.Read(result.SpanID[:])
+	return result
+}
+
+/*
+parseTraceparent parses traceparent header value
+(e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01)
+*/
+func parseTraceparent(value string) (result traceContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return
+	}
+
+	// version 00 has exactly 4 parts, future versions can add more
+	if parts[0] == "00" && len(parts) != 4 {
+		return
+	}
+
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return
+	}
+
+	var flags [1]byte
+	if _, err := hex.Decode(result.TraceID[:], []byte(parts[1])); err != nil {
+		return
+	}
+	if _, err := hex.Decode(result.SpanID[:], []byte(parts[2])); err != nil {
+		return
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return
+	}
+	result.Flags = flags[0]
+
+	// all zeroes trace id and span id are invalid
+	ok = result.TraceID != [16]byte{} && result.SpanID != [8]byte{}
+	return
+}
+
+/*
+String returns traceparent header value
+*/
+func (t traceContext) String() string {
+	return fmt.Sprintf("00-%s-%s-%02x", t.TraceIDString(), t.SpanIDString(), t.Flags)
+}
+
+func (t traceContext) TraceIDString() string {
+	return hex.EncodeToString(t.TraceID[:])
+}
+
+func (t traceContext) SpanIDString() string {
+	return hex.EncodeToString(t.SpanID[:])
+}
+
+/*
+newRequestID returns random request id in uuid format
+*/
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+/*
+validRequestID returns whether request id given by client can be used
+*/
+func validRequestID(value string) bool {
+	if value == "" || len(value) > 128 {
+		return false
+	}
+	for _, c := range value {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+type traceContextKey struct{}
+
+/*
+withTraceContext returns context with current span
+*/
+func withTraceContext(ctx context.Context, trace traceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, trace)
+}
+
+/*
+getTraceContext returns current span from context
+*/
+func getTraceContext(ctx context.Context) (trace traceContext, ok bool) {
+	trace, ok = ctx.Value(traceContextKey{}).(traceContext)
+	return
+}
+
+/*
+setTraceHeaders sets request id and traceparent of current span to headers of
+outgoing request.
+*/
+func setTraceHeaders(ctx context.Context, header http.Header) {
+	if id := getRequestState(ctx).RequestID; id != "" {
+		header.Set(requestIDHeader, id)
+	}
+	if trace, ok := getTraceContext(ctx); ok {
+		header.Set(traceparentHeader, trace.String())
+	}
+}
+
+/*
+traceEnv returns environment variables with request id and traceparent of
+current span for commands.
+*/
+func traceEnv(ctx context.Context) (result []string) {
+	if id := getRequestState(ctx).RequestID; id != "" {
+		result = append(result, "GOEXPOSE_REQUEST_ID="+id)
+	}
+	if trace, ok := getTraceContext(ctx); ok {
+		result = append(result, "TRACEPARENT="+trace.String())
+	}
+	return
+}
+
+/*
+startSpan starts new span as child of current span in context. Returned context
+has new span as current span.
+*/
+func startSpan(ctx context.Context, name string, kind int) (context.Context, *span) {
+	s := &span{
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: map[string]interface{}{},
+	}
+
+	if parent, ok := getTraceContext(ctx); ok {
+		s.trace = parent
+		s.parent = parent.SpanID
+		rand.Read(s.trace.SpanID[:])
+	} else {
+		s.trace = newTraceContext()
+	}
+
+	return withTraceContext(ctx, s.trace), s
+}
+
+/*
+span is single operation in trace
+*/
+type span struct {
+	trace      traceContext
+	parent     [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	err        string
+}
+
+/*
+SetAttribute sets attribute of span
+*/
+func (s *span) SetAttribute(key string, value interface{}) *span {
+	s.attributes[key] = value
+	return s
+}
+
+/*
+SetError marks span as failed
+*/
+func (s *span) SetError(err interface{}) *span {
+	s.err = fmt.Sprintf("%v", err)
+	return s
+}
+
+/*
+End ends span and exports it if tracing is configured
+*/
+func (s *span) End() {
+	s.end = time.Now()
+
+	currentTracerLock.RLock()
+	t := currentTracer
+	currentTracerLock.RUnlock()
+
+	if t != nil {
+		t.Record(s)
+	}
+}
+
+/*
+TracingConfig is configuration of span exporting
+*/
+type TracingConfig struct {
+	// otlp (default) or file
+	Exporter string `json:"exporter"`
+
+	// otlp http endpoint (e.g. http://localhost:4318/v1/traces) and additional headers
+	Endpoint string            `json:"endpoint"`
+	Headers  map[string]string `json:"headers"`
+
+	// file for file exporter, spans are appended as otlp json lines
+	File string `json:"file"`
+
+	// service name reported in spans, default is goexpose
+	ServiceName string `json:"service_name"`
+
+	// how often spans are exported
+	FlushInterval Duration `json:"flush_interval"`
+}
+
+/*
+Validate validates tracing configuration
+*/
+func (t *TracingConfig) Validate() (err error) {
+	t.Exporter = strings.TrimSpace(strings.ToLower(t.Exporter))
+	if t.Exporter == "" {
+		t.Exporter = "otlp"
+	}
+
+	switch t.Exporter {
+	case "otlp":
+		if t.Endpoint = strings.TrimSpace(t.Endpoint); t.Endpoint == "" {
+			return fmt.Errorf("tracing: otlp exporter needs endpoint")
+		}
+	case "file":
+		if t.File = strings.TrimSpace(t.File); t.File == "" {
+			return fmt.Errorf("tracing: file exporter needs file")
+		}
+	default:
+		return fmt.Errorf("tracing: unknown exporter %s", t.Exporter)
+	}
+
+	if t.ServiceName = strings.TrimSpace(t.ServiceName); t.ServiceName == "" {
+		t.ServiceName = "goexpose"
+	}
+
+	if t.FlushInterval < 0 {
+		return fmt.Errorf("tracing: invalid flush_interval")
+	}
+	if t.FlushInterval == 0 {
+		t.FlushInterval = Duration(DEFAULT_TRACING_FLUSH_INTERVAL)
+	}
+	return
+}
+
+/*
+setupTracing validates tracing configuration and starts tracer, previous tracer
+is stopped.
+*/
+func setupTracing(config *TracingConfig) (err error) {
+	var t *tracer
+	if t, err = tracerFromConfig(config); err != nil {
+		return
+	}
+
+	setTracer(t)
+	return
+}
+
+/*
+tracerFromConfig validates tracing configuration and returns tracer (nil when
+tracing is not configured), tracer is not set.
+*/
+func tracerFromConfig(config *TracingConfig) (result *tracer, err error) {
+	if config == nil {
+		return
+	}
+	if err = config.Validate(); err != nil {
+		return
+	}
+	return newTracer(config)
+}
+
+/*
+setTracer sets current tracer, previous tracer exports remaining spans and is closed
+*/
+func setTracer(t *tracer) {
+	currentTracerLock.Lock()
+	old := currentTracer
+	currentTracer = t
+	currentTracerLock.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+}
+
+/*
+spanExporter exports otlp json payload
+*/
+type spanExporter interface {
+	Export(payload []byte) error
+	Close() error
+}
+
+/*
+newTracer returns tracer that exports spans in batches
+*/
+func newTracer(config *TracingConfig) (result *tracer, err error) {
+	var exporter spanExporter
+	switch config.Exporter {
+	case "file":
+		var file *os.File
+		if file, err = os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			return
+		}
+		exporter = &fileExporter{file: file}
+	default:
+		exporter = &otlpExporter{
+			endpoint: config.Endpoint,
+			headers:  config.Headers,
+			client:   &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+
+	result = &tracer{
+		service:  config.ServiceName,
+		exporter: exporter,
+		interval: time.Duration(config.FlushInterval),
+		spans:    make(chan *span, DEFAULT_TRACING_QUEUE_SIZE),
+		done:     make(chan struct{}),
+	}
+	result.wg.Add(1)
+	go result.run()
+	return
+}
+
+/*
+tracer collects finished spans and exports them
+*/
+type tracer struct {
+	service  string
+	exporter spanExporter
+	interval time.Duration
+	spans    chan *span
+	done     chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+/*
+Record queues span for export, when queue is full span is dropped
+*/
+func (t *tracer) Record(s *span) {
+	select {
+	case t.spans <- s:
+	default:
+		logger().Warningf("Tracing queue is full, dropping span %s", s.name)
+	}
+}
+
+/*
+run exports spans every interval or when batch is full
+*/
+func (t *tracer) run() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	batch := []*span{}
+	for {
+		select {
+		case s := <-t.spans:
+			if batch = append(batch, s); len(batch) >= DEFAULT_TRACING_BATCH_SIZE {
+				batch = t.export(batch)
+			}
+		case <-ticker.C:
+			batch = t.export(batch)
+		case <-t.done:
+			for {
+				select {
+				case s := <-t.spans:
+					batch = append(batch, s)
+				default:
+					t.export(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+/*
+export exports batch of spans and returns empty batch
+*/
+func (t *tracer) export(batch []*span) []*span {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	payload, err := json.Marshal(otlpPayload(t.service, batch))
+	if err == nil {
+		err = t.exporter.Export(payload)
+	}
+	if err != nil {
+		logger().Errorf("Export of %d span(s) failed: %v", len(batch), err)
+	}
+	return batch[:0]
+}
+
+/*
+Close exports remaining spans and closes exporter
+*/
+func (t *tracer) Close() {
+	t.once.Do(func() {
+		close(t.done)
+		t.wg.Wait()
+		t.exporter.Close()
+	})
+}
+
+/*
+otlpPayload returns otlp ExportTraceServiceRequest for spans
+*/
+func otlpPayload(service string, spans []*span) map[string]interface{} {
+	items := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		item := map[string]interface{}{
+			"traceId":           s.trace.TraceIDString(),
+			"spanId":            s.trace.SpanIDString(),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attributes),
+			"status":            map[string]interface{}{"code": 1},
+		}
+		if s.parent != [8]byte{} {
+			item["parentSpanId"] = hex.EncodeToString(s.parent[:])
+		}
+		if s.err != "" {
+			item["status"] = map[string]interface{}{"code": 2, "message": s.err}
+		}
+		items = append(items, item)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]interface{}{"service.name": service}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "goexpose", "version": VERSION},
+						"spans": items,
+					},
+				},
+			},
+		},
+	}
+}
+
+/*
+otlpAttributes returns attributes in otlp format
+*/
+func otlpAttributes(attributes map[string]interface{}) []interface{} {
+	result := make([]interface{}, 0, len(attributes))
+	for key, value := range attributes {
+		var v map[string]interface{}
+		switch value := value.(type) {
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(value)}
+		case bool:
+			v = map[string]interface{}{"boolValue": value}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprintf("%v", value)}
+		}
+		result = append(result, map[string]interface{}{"key": key, "value": v})
+	}
+	return result
+}
+
+/*
+otlpExporter posts spans to otlp http endpoint
+*/
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+func (o *otlpExporter) Export(payload []byte) (err error) {
+	var req *http.Request
+	if req, err = http.NewRequest("POST", o.endpoint, bytes.NewReader(payload)); err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range o.headers {
+		req.Header.Set(key, value)
+	}
+
+	var resp *http.Response
+	if resp, err = o.client.Do(req); err != nil {
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return
+}
+
+func (o *otlpExporter) Close() error {
+	return nil
+}
+
+/*
+fileExporter appends spans to file, one otlp json payload per line
+*/
+type fileExporter struct {
+	file *os.File
+}
+
+func (f *fileExporter) Export(payload []byte) (err error) {
+	_, err = f.file.Write(append(payload, '\n'))
+	return
+}
+
+func (f *fileExporter) Close() error {
+	return f.file.Close()
+}
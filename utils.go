@@ -23,6 +23,18 @@ func MethodAllowed(method string, avail []string) bool {
 	return false
 }
 
+/*
+stringInSlice returns whether value is in values
+*/
+func stringInSlice(value string, values []string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 /*
 Interpolate
 
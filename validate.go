@@ -0,0 +1,289 @@
+package goexpose
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Configuration validation
+
+Configuration is decoded strictly, unknown fields are errors. Errors are
+reported as ConfigError with file, endpoint, method and json pointer of invalid
+value, so they can be found in configuration quickly.
+*/
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+/*
+ConfigError is error in configuration with location of invalid value
+*/
+type ConfigError struct {
+	// file that contains invalid value (if known)
+	File string
+
+	// endpoint path and method (if error is in endpoint)
+	Endpoint string
+	Method   string
+
+	// json pointer of invalid value in file
+	Pointer string
+
+	Err error
+}
+
+func (c *ConfigError) Error() string {
+	parts := []string{}
+	if c.File != "" {
+		parts = append(parts, c.File)
+	}
+	if c.Endpoint != "" {
+		endpoint := "endpoint " + c.Endpoint
+		if c.Method != "" {
+			endpoint += " " + c.Method
+		}
+		parts = append(parts, endpoint)
+	}
+	if c.Pointer != "" {
+		parts = append(parts, c.Pointer)
+	}
+	parts = append(parts, c.Err.Error())
+	return strings.Join(parts, ": ")
+}
+
+func (c *ConfigError) Unwrap() error {
+	return c.Err
+}
+
+/*
+ConfigErrors is list of all errors found in configuration
+*/
+type ConfigErrors []error
+
+func (c ConfigErrors) Error() string {
+	messages := make([]string, 0, len(c))
+	for _, err := range c {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+/*
+Err returns nil when there are no errors
+*/
+func (c ConfigErrors) Err() error {
+	if len(c) == 0 {
+		return nil
+	}
+	return c
+}
+
+/*
+Add adds error, ConfigErrors are flattened
+*/
+func (c *ConfigErrors) Add(err error) {
+	if errs, ok := err.(ConfigErrors); ok {
+		*c = append(*c, errs...)
+	} else if err != nil {
+		*c = append(*c, err)
+	}
+}
+
+/*
+configSource is location of configuration part (e.g. endpoint) in file
+*/
+type configSource struct {
+	File    string
+	Pointer string
+}
+
+/*
+Wrap returns ConfigError with location of source. If err is already ConfigError
+(e.g. from decodeConfig), pointer is appended to pointer of source. Nil error is
+returned as nil.
+*/
+func (c configSource) Wrap(err error, pointer string) error {
+	if err == nil {
+		return nil
+	}
+
+	result := &ConfigError{
+		File:    c.File,
+		Pointer: c.Pointer + pointer,
+		Err:     err,
+	}
+
+	var ce *ConfigError
+	if errors.As(err, &ce) {
+		result.Pointer += ce.Pointer
+		result.Err = ce.Err
+	}
+	return result
+}
+
+/*
+WrapEndpoint returns ConfigError with location of source and endpoint
+*/
+func (c configSource) WrapEndpoint(err error, pointer, endpoint, method string) error {
+	if err == nil {
+		return nil
+	}
+
+	result := c.Wrap(err, pointer).(*ConfigError)
+	result.Endpoint = endpoint
+	result.Method = method
+	return result
+}
+
+/*
+decodeConfig unmarshals json to target, unknown fields are errors. Errors are
+ConfigError with json pointer of invalid value.
+*/
+func decodeConfig(data []byte, target interface{}) (err error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return json.Unmarshal(data, target)
+	}
+
+	if err = checkUnknownFields(data, reflect.TypeOf(target), ""); err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(data, target); err != nil {
+		if te, ok := err.(*json.UnmarshalTypeError); ok {
+			pointer := ""
+			if te.Field != "" {
+				pointer = "/" + strings.Replace(te.Field, ".", "/", -1)
+			}
+			return &ConfigError{Pointer: pointer, Err: fmt.Errorf("cannot use %s as %s", te.Value, te.Type)}
+		}
+		return &ConfigError{Err: err}
+	}
+	return
+}
+
+/*
+checkUnknownFields checks that all fields in json objects exist in target type.
+Values of types that implement json.Unmarshaler are unmarshalled here, so their
+errors (e.g. invalid duration) have location too. Interfaces are not checked.
+*/
+func checkUnknownFields(data []byte, t reflect.Type, pointer string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		if string(bytes.TrimSpace(data)) == "null" {
+			return nil
+		}
+		if err := reflect.New(t).Interface().(json.Unmarshaler).UnmarshalJSON(data); err != nil {
+			return &ConfigError{Pointer: pointer, Err: err}
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object := map[string]json.RawMessage{}
+		if json.Unmarshal(data, &object) != nil {
+			// type error is reported by unmarshal
+			return nil
+		}
+
+		fields := jsonFields(t)
+
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			field, ok := fields[strings.ToLower(name)]
+			if !ok {
+				return &ConfigError{Pointer: pointer + "/" + escapePointer(name), Err: errors.New("unknown field")}
+			}
+			if err := checkUnknownFields(object[name], field.Type, pointer+"/"+escapePointer(name)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		object := map[string]json.RawMessage{}
+		if json.Unmarshal(data, &object) != nil {
+			return nil
+		}
+		for name, value := range object {
+			if err := checkUnknownFields(value, t.Elem(), pointer+"/"+escapePointer(name)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		items := []json.RawMessage{}
+		if json.Unmarshal(data, &items) != nil {
+			return nil
+		}
+		for i, item := range items {
+			if err := checkUnknownFields(item, t.Elem(), pointer+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+jsonFields returns fields of struct by lowercased json name, fields of embedded
+structs are included.
+*/
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	result := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		// embedded struct without name
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for key, value := range jsonFields(embedded) {
+					if _, ok := result[key]; !ok {
+						result[key] = value
+					}
+				}
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		result[strings.ToLower(name)] = field
+	}
+	return result
+}
+
+/*
+Validate validates configuration and runs all task and authorizer factories
+without starting server. All errors are returned.
+*/
+func (s *Server) Validate() error {
+	_, err := s.router()
+	return err
+}
@@ -0,0 +1,47 @@
+package goexpose
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDecodeConfig(t *testing.T) {
+
+	Convey("Test unknown fields", t, func() {
+		target := &MultiTaskConfig{}
+		err := decodeConfig([]byte(`{"tasks": [{"type": "info", "descripton": "x"}]}`), target)
+		So(err, ShouldNotBeNil)
+
+		ce, ok := err.(*ConfigError)
+		So(ok, ShouldBeTrue)
+		So(ce.Pointer, ShouldEqual, "/tasks/0/descripton")
+
+		So(decodeConfig([]byte(`{"tasks": [{"type": "info", "Description": "x"}]}`), target), ShouldBeNil)
+	})
+
+	Convey("Test invalid values", t, func() {
+		err := decodeConfig([]byte(`{"tasks": [{"type": "info", "timeout": "x"}]}`), &MultiTaskConfig{})
+		So(err, ShouldNotBeNil)
+		So(err.(*ConfigError).Pointer, ShouldEqual, "/tasks/0/timeout")
+
+		err = decodeConfig([]byte(`{"tasks": [{"type": 1}]}`), &MultiTaskConfig{})
+		So(err, ShouldNotBeNil)
+		So(err.(*ConfigError).Pointer, ShouldEqual, "/tasks/0/type")
+	})
+
+	Convey("Test error location", t, func() {
+		source := configSource{File: "config.json", Pointer: "/endpoints/1"}
+		So(source.Wrap(nil, "/config"), ShouldBeNil)
+
+		err := source.WrapEndpoint(&ConfigError{Pointer: "/query", Err: errors.New("unknown field")}, "/methods/GET/config", "/users", "GET")
+		So(err.Error(), ShouldEqual, "config.json: endpoint /users GET: /endpoints/1/methods/GET/config/query: unknown field")
+
+		errs := ConfigErrors{}
+		errs.Add(nil)
+		So(errs.Err(), ShouldBeNil)
+		errs.Add(ConfigErrors{errors.New("a"), errors.New("b")})
+		So(len(errs), ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,239 @@
+package goexpose
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Variables in configuration
+
+String values in configuration can contain variables that are resolved when
+configuration is loaded (unlike interpolation that is done on every request):
+
+	${env:NAME}            value of environment variable NAME
+	${env:NAME:-default}   value of NAME, default if NAME is not set or empty
+	${file:/run/x}         contents of file (trailing newline is removed), relative
+	                       paths are relative to configuration directory
+	${file:/run/x:-y}      contents of file, default if file does not exist
+	$${env:NAME}           literal ${env:NAME}
+
+Other ${...} expressions (e.g. ${HOME} in shell commands) are left unchanged,
+so they are resolved by shell when task runs.
+
+Values starting with "enc:" are encrypted secrets (see secrets.go), they are
+decrypted as whole and variables in them are not resolved.
+
+Variables without default that cannot be resolved are configuration errors.
+*/
+
+const (
+	// prefix of variable that reads environment variable
+	variableEnvPrefix = "env:"
+
+	// prefix of variable that reads file
+	variableFilePrefix = "file:"
+
+	// separator of default value
+	variableDefaultSeparator = ":-"
+)
+
+/*
+substituteVariables resolves variables and decrypts encrypted values (when key
+is given) in all string values of json document. Errors contain json pointer of
+value.
+*/
+func substituteVariables(document []byte, directory string, key []byte) (result []byte, err error) {
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	if err = decoder.Decode(&value); err != nil {
+		return
+	}
+
+	if value, err = substituteValue(value, directory, key, ""); err != nil {
+		return
+	}
+
+	return json.Marshal(value)
+}
+
+/*
+substituteValue resolves variables in value recursively, pointer is json
+pointer of value.
+*/
+func substituteValue(value interface{}, directory string, key []byte, pointer string) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		var (
+			result string
+			err    error
+		)
+		if isSecret(value) {
+			result, err = DecryptSecret(key, value)
+		} else {
+			result, err = expandVariables(value, directory)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pointer, err)
+		}
+		return result, nil
+	case []interface{}:
+		for i, item := range value {
+			resolved, err := substituteValue(item, directory, key, pointer+"/"+strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			value[i] = resolved
+		}
+	case map[string]interface{}:
+		// stable order so first error is always the same
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			resolved, err := substituteValue(value[name], directory, key, pointer+"/"+escapePointer(name))
+			if err != nil {
+				return nil, err
+			}
+			value[name] = resolved
+		}
+	}
+	return value, nil
+}
+
+/*
+findVariables adds values of json document that contain variables to originals
+by their resolved value, so configuration dump prints variables instead of
+resolved values. Empty resolved values are skipped.
+*/
+func findVariables(document []byte, directory string, originals map[string]string) {
+	var value interface{}
+	if json.Unmarshal(document, &value) != nil {
+		return
+	}
+
+	mapStrings(value, func(s string) string {
+		if isSecret(s) || !strings.Contains(s, "${") {
+			return s
+		}
+		if resolved, err := expandVariables(s, directory); err == nil && resolved != "" && resolved != s {
+			originals[resolved] = s
+		}
+		return s
+	})
+}
+
+/*
+expandVariables resolves all variables in string
+*/
+func expandVariables(value, directory string) (string, error) {
+	if !strings.Contains(value, "$") {
+		return value, nil
+	}
+
+	var result bytes.Buffer
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' {
+			result.WriteByte(value[i])
+			continue
+		}
+
+		// escaped variable
+		if strings.HasPrefix(value[i:], "$") && isVariable(value[i+1:]) {
+			result.WriteString("${")
+			i += 2
+			continue
+		}
+
+		// only ${env:...} and ${file:...} are variables
+		if !isVariable(value[i:]) {
+			result.WriteByte('$')
+			continue
+		}
+
+		end := strings.Index(value[i:], "}")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated variable %s", value[i:])
+		}
+
+		resolved, err := resolveVariable(value[i+2:i+end], directory)
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(resolved)
+		i += end
+	}
+
+	return result.String(), nil
+}
+
+/*
+isVariable returns whether value starts with ${env:...} or ${file:...} variable
+*/
+func isVariable(value string) bool {
+	return strings.HasPrefix(value, "${"+variableEnvPrefix) || strings.HasPrefix(value, "${"+variableFilePrefix)
+}
+
+/*
+resolveVariable returns value of variable expression (content of ${...})
+*/
+func resolveVariable(expression, directory string) (result string, err error) {
+	name, def, hasDefault := expression, "", false
+	if index := strings.Index(expression, variableDefaultSeparator); index != -1 {
+		name, def, hasDefault = expression[:index], expression[index+len(variableDefaultSeparator):], true
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		return "", fmt.Errorf("empty variable ${%s}", expression)
+	}
+
+	// file variable
+	if strings.HasPrefix(name, variableFilePrefix) {
+		filename := strings.TrimSpace(strings.TrimPrefix(name, variableFilePrefix))
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(directory, filename)
+		}
+
+		var body []byte
+		if body, err = ioutil.ReadFile(filename); err != nil {
+			if hasDefault && os.IsNotExist(err) {
+				return def, nil
+			}
+			return "", fmt.Errorf("cannot read file %s: %v", filename, err)
+		}
+		return strings.TrimRight(string(body), "\r\n"), nil
+	}
+
+	// environment variable
+	name = strings.TrimSpace(strings.TrimPrefix(name, variableEnvPrefix))
+	if name == "" {
+		return "", fmt.Errorf("empty variable ${%s}", expression)
+	}
+	value, ok := os.LookupEnv(name)
+	if hasDefault && value == "" {
+		return def, nil
+	}
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+/*
+escapePointer escapes json pointer reference token
+*/
+func escapePointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
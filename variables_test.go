@@ -0,0 +1,60 @@
+package goexpose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVariables(t *testing.T) {
+
+	Convey("Test environment variables", t, func() {
+		os.Setenv("GOEXPOSE_TEST_VAR", "value")
+		defer os.Unsetenv("GOEXPOSE_TEST_VAR")
+
+		result, err := expandVariables("a ${env:GOEXPOSE_TEST_VAR} ${env:GOEXPOSE_TEST_MISSING:-default} $$${env:GOEXPOSE_TEST_VAR} $HOME", "")
+		So(err, ShouldBeNil)
+		So(result, ShouldEqual, "a value default $${env:GOEXPOSE_TEST_VAR} $HOME")
+
+		_, err = expandVariables("${env:GOEXPOSE_TEST_MISSING}", "")
+		So(err, ShouldNotBeNil)
+
+		_, err = expandVariables("${env:GOEXPOSE_TEST_VAR", "")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Test file variables", t, func() {
+		directory, err := ioutil.TempDir("", "goexpose")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(directory)
+
+		So(ioutil.WriteFile(filepath.Join(directory, "secret"), []byte("password\n"), 0600), ShouldBeNil)
+
+		result, err := expandVariables("${file:secret}:${file:missing:-none}", directory)
+		So(err, ShouldBeNil)
+		So(result, ShouldEqual, "password:none")
+
+		_, err = expandVariables("${file:missing}", directory)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Test substitution in document", t, func() {
+		result, err := substituteVariables([]byte(`{"port": 9980, "a": [{"b/c": "${env:GOEXPOSE_TEST_MISSING:-x}"}]}`), "", nil)
+		So(err, ShouldBeNil)
+		So(string(result), ShouldEqual, `{"a":[{"b/c":"x"}],"port":9980}`)
+
+		_, err = substituteVariables([]byte(`{"a": [{"b/c": "${env:GOEXPOSE_TEST_MISSING}"}]}`), "", nil)
+		So(err.Error(), ShouldStartWith, "/a/0/b~1c: ")
+	})
+
+	Convey("Test shell references are not resolved", t, func() {
+		os.Unsetenv("GOEXPOSE_TEST_MISSING")
+
+		result, err := substituteVariables([]byte(`{"commands": [{"command": "echo ${HOME} ${GOEXPOSE_TEST_MISSING} $${HOME} $HOME"}]}`), "", nil)
+		So(err, ShouldBeNil)
+		So(string(result), ShouldEqual, `{"commands":[{"command":"echo ${HOME} ${GOEXPOSE_TEST_MISSING} $${HOME} $HOME"}]}`)
+	})
+}
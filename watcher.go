@@ -0,0 +1,134 @@
+package goexpose
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+fileStamp identifies version of file
+*/
+type fileStamp struct {
+	modtime time.Time
+	size    int64
+	exists  bool
+}
+
+func newFileStamp(filename string) (result fileStamp) {
+	if info, err := os.Stat(filename); err == nil {
+		result = fileStamp{
+			modtime: info.ModTime(),
+			size:    info.Size(),
+			exists:  true,
+		}
+	}
+	return
+}
+
+/*
+newFileWatcher returns watcher that polls given files every interval and calls
+callback with list of changed files.
+*/
+func newFileWatcher(interval time.Duration, callback func(changed []string), files ...string) *fileWatcher {
+	w := &fileWatcher{
+		interval: interval,
+		callback: callback,
+		stop:     make(chan struct{}),
+	}
+	w.SetFiles(files...)
+	return w
+}
+
+/*
+fileWatcher watches files by polling their modification time and size.
+Polling is used so it works on every platform and also with files that are
+replaced by symlink swapping (e.g. kubernetes secrets).
+*/
+type fileWatcher struct {
+	lock     sync.Mutex
+	interval time.Duration
+	callback func(changed []string)
+	stamps   map[string]fileStamp
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+/*
+SetFiles changes set of watched files, current state of files is remembered.
+*/
+func (w *fileWatcher) SetFiles(files ...string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.stamps = make(map[string]fileStamp, len(files))
+	for _, filename := range files {
+		w.stamps[filename] = newFileStamp(filename)
+	}
+}
+
+/*
+Reset forgets state of files, so all existing files are reported as changed on next check.
+*/
+func (w *fileWatcher) Reset() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for filename := range w.stamps {
+		w.stamps[filename] = fileStamp{}
+	}
+}
+
+/*
+Check checks all files and calls callback if any of them has changed
+*/
+func (w *fileWatcher) Check() {
+	if changed := w.Changed(); len(changed) > 0 && w.callback != nil {
+		w.callback(changed)
+	}
+}
+
+/*
+Changed checks all files and returns files changed since last check, callback
+is not called.
+*/
+func (w *fileWatcher) Changed() (changed []string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for filename, stamp := range w.stamps {
+		if current := newFileStamp(filename); current != stamp {
+			w.stamps[filename] = current
+			changed = append(changed, filename)
+		}
+	}
+	return
+}
+
+/*
+Start starts polling in separate goroutine
+*/
+func (w *fileWatcher) Start() *fileWatcher {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.Check()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	return w
+}
+
+/*
+Stop stops polling
+*/
+func (w *fileWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}